@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/crholm/mark"
+	"github.com/crholm/mark/internal/config"
+	"github.com/crholm/mark/internal/fss"
+	"github.com/modfin/henry/slicez"
+	"github.com/urfave/cli/v2"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var imageExts = map[string]bool{".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true, ".webp": true}
+
+// attachmentLink returns the markdown link to an attachment, as an image if it is one
+func attachmentLink(a mark.Attachment) string {
+	link := fmt.Sprintf("[%s](%s)", a.Name, fss.AssetLink(a.Asset))
+	if imageExts[strings.ToLower(filepath.Ext(a.Asset))] {
+		link = "!" + link
+	}
+	return link
+}
+
+func attach(c *cli.Context, cfg config.Config) error {
+	if c.NArg() < 2 {
+		return errors.New("a note and at least one file to attach must be specified, e.g. mark attach <note> photo.png")
+	}
+	name, err := findNote(c.Args().First(), cfg)
+	if err != nil {
+		return err
+	}
+	attached, err := attachFiles(name, c.Args().Tail(), cfg)
+	if err != nil {
+		return err
+	}
+	var assets []string
+	for i, a := range attached {
+		fmt.Println("attached", c.Args().Tail()[i], "as", a.Asset)
+		assets = append(assets, a.Asset)
+	}
+	autoCommit(name, "attach", cfg, assets...)
+	return nil
+}
+
+// attachFiles stores the files as assets, records them as attachments of the note and appends a link to each of them
+func attachFiles(name string, files []string, cfg config.Config) ([]mark.Attachment, error) {
+	meta, content, err := readNote(name)
+	if err != nil {
+		return nil, err
+	}
+	if meta.Encrypted {
+		fmt.Fprintln(os.Stderr, "warning: attachments are not encrypted, only the body of", name, "is")
+	}
+
+	var attached []mark.Attachment
+	var links []string
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		a := mark.Attachment{Name: filepath.Base(file), Asset: fss.AssetName(file, data)}
+		err = store.PutAsset(a.Asset, data)
+		if err != nil {
+			return nil, err
+		}
+		if !slicez.Contains(meta.Attachments, a) {
+			meta.Attachments = append(meta.Attachments, a)
+		}
+		attached = append(attached, a)
+		links = append(links, attachmentLink(a))
+	}
+
+	// each link is a paragraph of its own, so that images are not rendered inline with the preceding text
+	if len(content) > 0 {
+		content = append(bytes.TrimRight(content, "\n"), "\n\n"...)
+	}
+	content = append(content, strings.Join(links, "\n\n")...)
+	meta.UpdatedAt = time.Now()
+	data, err := marshalNote(meta, content)
+	if err != nil {
+		return nil, err
+	}
+	err = store.Put(name, data)
+	if err != nil {
+		return nil, err
+	}
+	return attached, updateIndex(name, cfg)
+}
+
+// fsck reports assets not attached to any note, and attachments whose asset is missing
+func fsck(c *cli.Context, cfg config.Config) error {
+	// only the headers are read, to not ask for the passphrase of encrypted notes
+	var notes []linkedNote
+	err := store.Walk(func(name string, data []byte) error {
+		header, _, err := mark.UnmarshalNote(data)
+		if err != nil {
+			return fmt.Errorf("could not read note %s: %w", name, err)
+		}
+		notes = append(notes, linkedNote{file: name, header: header})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	assets, err := store.ListAssets()
+	if err != nil {
+		return err
+	}
+	stored := map[string]bool{}
+	for _, a := range assets {
+		stored[a] = true
+	}
+
+	var problems int
+	attached := map[string]bool{}
+	for _, n := range notes {
+		for _, a := range n.header.Attachments {
+			attached[a.Asset] = true
+			if !stored[a.Asset] {
+				fmt.Printf("missing asset    %s -> %s (%s)\n", filepath.Base(n.file), a.Asset, a.Name)
+				problems++
+			}
+		}
+	}
+
+	var pruned int
+	for _, a := range assets {
+		if attached[a] {
+			continue
+		}
+		if !c.Bool("prune") {
+			fmt.Printf("orphaned asset   %s\n", a)
+			problems++
+			continue
+		}
+		err = store.DeleteAsset(a)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		fmt.Printf("pruned asset     %s\n", a)
+		pruned++
+	}
+
+	if pruned > 0 {
+		fmt.Printf("pruned %d of %d assets\n", pruned, len(assets))
+	}
+	if problems > 0 {
+		return cli.Exit("", 1)
+	}
+	if pruned == 0 {
+		fmt.Println("all assets are attached and all attachments are stored")
+	}
+	return nil
+}
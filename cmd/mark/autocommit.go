@@ -0,0 +1,206 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/crholm/mark"
+	"github.com/crholm/mark/internal/config"
+	"github.com/crholm/mark/internal/fss"
+	"github.com/crholm/mark/internal/git"
+	"github.com/modfin/henry/slicez"
+	"github.com/urfave/cli/v2"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// autocommitWindow is how long changes in quick succession are batched into the same autocommit
+const autocommitWindow = 2 * time.Minute
+
+// autocommitSubject matches the subject of autocommits, e.g. "edit: Groceries" or "autocommit: 3 changes"
+var autocommitSubject = regexp.MustCompile(`^((new|edit|append|rm|restore|encrypt|decrypt|attach): |autocommit: [0-9]+ changes$)`)
+
+// autoCommit commits the change of a note, along with the assets attached to it, if sync.autocommit is set. The
+// change is already saved, so failing to commit it is a warning, the change is committed by the next mark sync
+func autoCommit(name string, verb string, cfg config.Config, assets ...string) {
+	err := commitChange(name, verb, cfg, assets...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "warning:", err)
+	}
+}
+
+// commitChange commits the change of a note, if sync.autocommit is set, e.g. as "edit: Groceries". The verb is used
+// for edited notes, added and removed notes are "new" and "rm". If the last commit is an autocommit of less than
+// autocommitWindow ago that is not pushed, the change is amended to it
+func commitChange(name string, verb string, cfg config.Config, assets ...string) error {
+	if !cfg.Sync.Autocommit {
+		return nil
+	}
+	if _, ok := store.(*fss.FS); !ok {
+		return nil
+	}
+	r, err := storageRepo()
+	if err != nil {
+		return err
+	}
+	err = commitNote(r, name, verb, assets...)
+	if errors.Is(err, git.ErrNotInstalled) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("could not autocommit %s, sync.autocommit is set: %w", name, err)
+	}
+	if cfg.Sync.Autopush {
+		return startAutopush(r)
+	}
+	return nil
+}
+
+func commitNote(r git.Runner, name string, verb string, assets ...string) error {
+	if !r.IsRepo() {
+		return fmt.Errorf("the notes at %s are not in a git repo", r.Dir)
+	}
+	err := ignoreIndexes(r)
+	if err != nil {
+		return err
+	}
+	p := path.Join("lib", name)
+	changes, err := r.Status()
+	if err != nil {
+		return err
+	}
+	change, ok := slicez.Find(changes, func(c git.Change) bool {
+		return c.Path == p
+	})
+	if !ok {
+		return nil
+	}
+
+	var data []byte
+	switch change.Status {
+	case git.Added:
+		verb = "new"
+		data, _ = store.Get(name)
+	case git.Deleted:
+		verb = "rm"
+		data, _ = r.Show("HEAD", p)
+	default:
+		data, _ = store.Get(name)
+	}
+	title := path.Base(name)
+	if header, _, err := mark.UnmarshalNote(data); err == nil && len(strings.TrimSpace(header.Title)) > 0 {
+		title = strings.TrimSpace(header.Title)
+	}
+	line := fmt.Sprintf("%s: %s", verb, title)
+
+	paths := []string{".gitignore", p}
+	for _, a := range assets {
+		paths = append(paths, path.Join("assets", a))
+	}
+	err = r.Add(paths...)
+	if err != nil {
+		return err
+	}
+	head, err := r.Head()
+	if err != nil || !autocommitSubject.MatchString(head.Subject) || time.Since(head.Date) > autocommitWindow ||
+		r.Pushed("HEAD") {
+		return r.Commit(line)
+	}
+	return r.Amend(batchMessage(head, line))
+}
+
+// batchMessage returns the message of an autocommit with another change amended to it, i.e. "autocommit: 2 changes"
+// followed by a line per change
+func batchMessage(head git.Commit, line string) string {
+	lines := []string{head.Subject}
+	if strings.HasPrefix(head.Subject, "autocommit: ") {
+		lines = strings.Split(head.Body, "\n")
+	}
+	lines = append(lines, line)
+	return fmt.Sprintf("autocommit: %d changes\n\n%s", len(lines), strings.Join(lines, "\n"))
+}
+
+// startAutopush pushes in a detached mark process, so that the editor returns without waiting on the network
+func startAutopush(r git.Runner) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(exe, "--store", r.Dir, "autopush")
+	detach(cmd)
+	err = cmd.Start()
+	if err != nil {
+		return fmt.Errorf("could not start pushing in the background: %w", err)
+	}
+	return cmd.Process.Release()
+}
+
+// autopushDelays are the waits before each attempt to push, starting with a short one to batch changes made in
+// quick succession
+var autopushDelays = []time.Duration{5 * time.Second, 10 * time.Second, 30 * time.Second, time.Minute, 5 * time.Minute}
+
+// autopush pushes the autocommits, retrying with backoff. It is run by startAutopush and logs to the .git dir
+func autopush(c *cli.Context, cfg config.Config) error {
+	r, err := storageRepo()
+	if err != nil {
+		return err
+	}
+	dir, err := r.GitDir()
+	if err != nil {
+		return err
+	}
+
+	// a single autopush at a time, which pushes whatever is committed while it waits
+	lock := filepath.Join(dir, "mark-autopush.lock")
+	if info, err := os.Stat(lock); err == nil && time.Since(info.ModTime()) > 2*totalDelay(autopushDelays) {
+		_ = os.Remove(lock)
+	}
+	f, err := os.OpenFile(lock, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return nil
+	}
+	_ = f.Close()
+	defer os.Remove(lock)
+
+	logf := func(format string, args ...interface{}) {
+		f, err := os.OpenFile(filepath.Join(dir, "mark-autopush.log"), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return
+		}
+		defer f.Close()
+		fmt.Fprintf(f, "%s %s\n", time.Now().Format(time.RFC3339), fmt.Sprintf(format, args...))
+	}
+
+	remote, branch, ok, err := syncTarget(r, cfg)
+	if err != nil || !ok {
+		logf("nothing to push to, set sync.remote in the config or an upstream of the branch")
+		return err
+	}
+	for attempt, delay := range autopushDelays {
+		time.Sleep(delay)
+		pushed, err := r.Rev("HEAD")
+		if err == nil {
+			err = r.Push(remote, branch)
+		}
+		if err != nil {
+			logf("could not push, attempt %d of %d: %v", attempt+1, len(autopushDelays), err)
+			continue
+		}
+		// changes committed while pushing are pushed by the next attempt
+		if head, err := r.Rev("HEAD"); err == nil && head == pushed {
+			return nil
+		}
+	}
+	logf("gave up pushing, run mark sync")
+	return nil
+}
+
+func totalDelay(delays []time.Duration) time.Duration {
+	return slicez.Fold(delays, func(acc time.Duration, d time.Duration) time.Duration {
+		return acc + d
+	}, 0)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/crholm/mark"
+	"github.com/crholm/mark/internal/config"
+	"github.com/crholm/mark/internal/crypt"
+	"github.com/crholm/mark/internal/fss"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/term"
+	"os"
+	"strings"
+)
+
+// passphrase is asked for at most once per run
+var passphrase string
+
+// getPassphrase returns $MARK_PASSPHRASE or prompts for it, twice if confirm is set, i.e. when encrypting
+func getPassphrase(confirm bool) (string, error) {
+	if len(passphrase) > 0 {
+		return passphrase, nil
+	}
+	if p := os.Getenv("MARK_PASSPHRASE"); len(p) > 0 {
+		passphrase = p
+		return passphrase, nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", errors.New("the passphrase of encrypted notes can not be prompted for, set MARK_PASSPHRASE")
+	}
+	read := func(prompt string) (string, error) {
+		fmt.Fprint(os.Stderr, prompt)
+		b, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return string(b), err
+	}
+	p, err := read("passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if confirm {
+		again, err := read("repeat passphrase: ")
+		if err != nil {
+			return "", err
+		}
+		if p != again {
+			return "", errors.New("the passphrases does not match")
+		}
+	}
+	passphrase = p
+	return passphrase, nil
+}
+
+// marshalNote marshals a note, encrypting the content if the note is to be encrypted
+func marshalNote(meta mark.Header, content []byte) ([]byte, error) {
+	body, err := encryptNote(meta, content)
+	if err != nil {
+		return nil, err
+	}
+	return mark.MarshalNote(meta, body)
+}
+
+// encryptNote returns the content of a note as it is stored, i.e. encrypted if the note is to be encrypted
+func encryptNote(meta mark.Header, content []byte) ([]byte, error) {
+	if !meta.Encrypted {
+		return content, nil
+	}
+	p, err := getPassphrase(true)
+	if err != nil {
+		return nil, err
+	}
+	return crypt.Encrypt(content, p)
+}
+
+// saveNewNote saves a new note, encrypting the content if the note is to be encrypted, see fss.SaveNote
+func saveNewNote(meta mark.Header, content []byte) (string, mark.Header, error) {
+	body, err := encryptNote(meta, content)
+	if err != nil {
+		return "", meta, err
+	}
+	return fss.SaveNote(store, meta, body)
+}
+
+// decryptNote returns the content of a note in the clear
+func decryptNote(meta mark.Header, content []byte) ([]byte, error) {
+	if !meta.Encrypted {
+		return content, nil
+	}
+	p, err := getPassphrase(false)
+	if err != nil {
+		return nil, err
+	}
+	return crypt.Decrypt(content, p)
+}
+
+func encryptCmd(c *cli.Context, cfg config.Config) error {
+	name, err := findNote(strings.Join(c.Args().Slice(), " "), cfg)
+	if err != nil {
+		return err
+	}
+	meta, content, err := readNote(name)
+	if err != nil {
+		return err
+	}
+	if meta.Encrypted {
+		fmt.Println(name, "is already encrypted")
+		return nil
+	}
+
+	meta.Encrypted = true
+	data, err := marshalNote(meta, content)
+	if err != nil {
+		return err
+	}
+	err = store.Put(name, data)
+	if err != nil {
+		return err
+	}
+	fmt.Println("encrypted", name)
+	// the words of the note are removed from the free text index, which is only done by rebuilding it
+	err = reindexAll(cfg)
+	if err != nil {
+		return err
+	}
+	autoCommit(name, "encrypt", cfg)
+	return nil
+}
+
+func decryptCmd(c *cli.Context, cfg config.Config) error {
+	name, err := findNote(strings.Join(c.Args().Slice(), " "), cfg)
+	if err != nil {
+		return err
+	}
+	meta, content, err := readNote(name)
+	if err != nil {
+		return err
+	}
+	if !meta.Encrypted {
+		fmt.Println(name, "is not encrypted")
+		return nil
+	}
+
+	meta.Encrypted = false
+	data, err := marshalNote(meta, content)
+	if err != nil {
+		return err
+	}
+	err = store.Put(name, data)
+	if err != nil {
+		return err
+	}
+	fmt.Println("decrypted", name)
+	err = updateIndex(name, cfg)
+	if err != nil {
+		return err
+	}
+	autoCommit(name, "decrypt", cfg)
+	return nil
+}
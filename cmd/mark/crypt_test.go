@@ -0,0 +1,65 @@
+package main
+
+import (
+	"github.com/crholm/mark"
+	"github.com/crholm/mark/internal/config"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEncryptAndDecrypt(t *testing.T) {
+	t.Setenv("MARK_PASSPHRASE", "correct horse battery staple")
+	passphrase = ""
+	t.Cleanup(func() { passphrase = "" })
+	cfg := config.Default()
+	todo, _, _, _ := saveLinkedNotes(t)
+
+	err := encryptCmd(contextOf(t, "2022-08-12"), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := store.Get(todo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, content, err := mark.UnmarshalNote(data)
+	if err != nil || !header.Encrypted || header.Title != "Todo" || strings.Contains(string(content), "Buy") {
+		t.Fatalf("expected the content to be encrypted and the header not, got %+v and %q, %v", header, content, err)
+	}
+	found, err := ls("buy")
+	if err != nil || len(found) != 0 {
+		t.Fatalf("expected the words of an encrypted note to be removed from the index, got %v, %v", found, err)
+	}
+
+	// links are found in encrypted notes as well
+	report, err := lintNotes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []brokenLink{{File: path.Base(todo), Link: "missing"}}
+	if !reflect.DeepEqual(report.BrokenLinks, expected) {
+		t.Fatalf("expected %v, got %v", expected, report.BrokenLinks)
+	}
+
+	passphrase = "wrong"
+	_, err = lintNotes()
+	if err == nil {
+		t.Fatal("expected an error reading an encrypted note with the wrong passphrase")
+	}
+	passphrase = ""
+
+	err = decryptCmd(contextOf(t, "2022-08-12"), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, content, err = readNote(todo)
+	if err != nil || header.Encrypted || string(content) != "Buy [[groceries|food]] and read [[missing]] #home" {
+		t.Fatalf("expected the note to be decrypted, got %+v and %q, %v", header, content, err)
+	}
+	found, err = ls("buy")
+	if err != nil || !reflect.DeepEqual(found, []string{todo}) {
+		t.Fatalf("expected the decrypted note to be indexed, got %v, %v", found, err)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !(aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris)
+
+package main
+
+import "os/exec"
+
+func detach(cmd *exec.Cmd) {}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/crholm/mark"
+	"github.com/crholm/mark/internal/bundle"
+	"github.com/crholm/mark/internal/config"
+	"github.com/crholm/mark/internal/site"
+	"github.com/crholm/mark/internal/tsar"
+	"github.com/modfin/henry/slicez"
+	"github.com/urfave/cli/v2"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+)
+
+func exportHTML(c *cli.Context, cfg config.Config) error {
+	dir := c.Args().Get(0)
+	if len(dir) == 0 {
+		return errors.New("a dir to export the site to must be specified")
+	}
+
+	files, err := ls(c.Args().Get(1))
+	if err != nil {
+		return err
+	}
+	index, err := readIndex()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	s := site.Site{
+		Tags:   map[string][]string{},
+		Words:  map[string][]string{},
+		Assets: map[string][]byte{},
+	}
+	for _, f := range files {
+		header, content, err := readNote(f)
+		if err != nil {
+			return fmt.Errorf("could not read note %s: %w", f, err)
+		}
+		s.Pages = append(s.Pages, site.Page{File: path.Base(f), Header: header, Content: content})
+		for _, a := range header.Attachments {
+			s.Assets[a.Asset], err = store.GetAsset(a.Asset)
+			if err != nil {
+				return fmt.Errorf("could not read attachment %s of %s: %w", a.Name, f, err)
+			}
+		}
+	}
+
+	for tag, ids := range index.TagsToId {
+		for _, id := range ids {
+			s.Tags[tag] = append(s.Tags[tag], index.IdToName[id])
+		}
+	}
+
+	data, err := store.ReadIndex("index.tsar")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if len(data) > 0 {
+		tsIndex, err := tsar.UnmarshalIndex(data)
+		if err != nil {
+			return err
+		}
+		for word, ids := range tsIndex.EntryList() {
+			for _, id := range ids {
+				s.Words[word] = append(s.Words[word], index.IdToName[int(id)])
+			}
+		}
+	}
+
+	notes, err := walkNotes()
+	if err != nil {
+		return err
+	}
+	s.Resolve = newLinkResolver(notes, index).resolve
+
+	err = site.Write(dir, s)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("exported %d notes to %s\n", len(s.Pages), dir)
+	return nil
+}
+
+func exportBundle(c *cli.Context, cfg config.Config) error {
+	files, err := ls(c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	var notes []bundle.Note
+	var assets []string
+	for _, f := range slicez.Sort(files) {
+		data, err := store.Get(f)
+		if err != nil {
+			return err
+		}
+		notes = append(notes, bundle.Note{Path: f, Data: data})
+		header, _, err := mark.UnmarshalNote(data)
+		if err != nil {
+			return fmt.Errorf("could not read note %s: %w", f, err)
+		}
+		for _, a := range header.Attachments {
+			assets = append(assets, a.Asset)
+		}
+	}
+	for _, a := range slicez.Sort(slicez.Uniq(assets)) {
+		data, err := store.GetAsset(a)
+		if err != nil {
+			return err
+		}
+		notes = append(notes, bundle.Note{Path: bundle.AssetPath(a), Data: data})
+	}
+
+	out := os.Stdout
+	if file := c.String("out"); len(file) > 0 && file != "-" {
+		out, err = os.Create(file)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+	return bundle.Write(out, c.String("format"), notes)
+}
+
+func importBundle(c *cli.Context, cfg config.Config) error {
+	var in io.Reader = os.Stdin
+	if file := c.Args().First(); len(file) > 0 && file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	notes, err := bundle.Read(in, c.String("format"))
+	if err != nil {
+		return err
+	}
+
+	var imported int
+	for _, n := range notes {
+		if asset, ok := bundle.IsAsset(n.Path); ok {
+			err = store.PutAsset(asset, n.Data)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		existing, err := store.Get(n.Path)
+		if err == nil && !bytes.Equal(existing, n.Data) && !c.Bool("force") {
+			fmt.Println("skipping", n.Path, "since it differs from the existing note, use --force to overwrite")
+			continue
+		}
+		err = store.Put(n.Path, n.Data)
+		if err != nil {
+			return err
+		}
+		imported++
+	}
+
+	fmt.Printf("imported %d notes, reindexing\n", imported)
+	return reindexAll(cfg)
+}
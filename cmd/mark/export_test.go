@@ -0,0 +1,53 @@
+package main
+
+import (
+	"github.com/crholm/mark/internal/bundle"
+	"github.com/crholm/mark/internal/config"
+	"github.com/crholm/mark/internal/fss"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// notesOf returns the notes of the store by their name
+func notesOf(t *testing.T) map[string]string {
+	t.Helper()
+	notes := map[string]string{}
+	err := store.Walk(func(name string, data []byte) error {
+		notes[name] = string(data)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return notes
+}
+
+func TestExportImportBundle(t *testing.T) {
+	for _, format := range bundle.Formats() {
+		store = fss.NewMemory()
+		saveNote(t, time.Date(2022, 8, 12, 14, 4, 49, 0, time.UTC), "Groceries", "Buy milk #home")
+		saveNote(t, time.Date(2022, 9, 1, 8, 0, 0, 0, time.UTC), "Meeting", "# Agenda\n\nAbout [[Groceries]] #work")
+		exported := notesOf(t)
+
+		out := filepath.Join(t.TempDir(), "notes."+format)
+		err := exportBundle(contextOf(t, "--format", format, "--out", out), config.Default())
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+
+		store = fss.NewMemory()
+		err = importBundle(contextOf(t, "--format", format, out), config.Default())
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if imported := notesOf(t); !reflect.DeepEqual(imported, exported) {
+			t.Fatalf("%s: expected the notes\n%v\nto be imported, got\n%v", format, exported, imported)
+		}
+		files, err := ls(":work")
+		if err != nil || !reflect.DeepEqual(files, []string{"2022/09/2022-09-01_08:00:00.000Z_Thursday.md"}) {
+			t.Fatalf("%s: expected the imported notes to be indexed, got %v, %v", format, files, err)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/crholm/mark/internal/config"
+	"github.com/modfin/henry/mapz"
+	"github.com/modfin/henry/slicez"
+	"github.com/urfave/cli/v2"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type graphNode struct {
+	ID        string     `json:"id"`
+	Kind      string     `json:"kind"`
+	Label     string     `json:"label"`
+	File      string     `json:"file,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+}
+
+type graphEdge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Kind   string `json:"kind"`
+}
+
+type noteGraph struct {
+	Nodes []graphNode `json:"nodes"`
+	Edges []graphEdge `json:"edges"`
+}
+
+func noteNodeId(name string) string {
+	return "note:" + name
+}
+func tagNodeId(tag string) string {
+	return "tag:" + tag
+}
+
+func buildGraph() (noteGraph, error) {
+	g := noteGraph{Nodes: []graphNode{}, Edges: []graphEdge{}}
+
+	notes, err := walkNotes()
+	if err != nil {
+		return g, err
+	}
+	index, err := readIndex()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return g, err
+	}
+	resolver := newLinkResolver(notes, index)
+
+	for _, n := range slicez.SortFunc(notes, func(a, b linkedNote) bool { return a.file < b.file }) {
+		name := filepath.Base(n.file)
+		created := n.header.CreatedAt
+		label := strings.TrimSpace(n.header.Title)
+		if len(label) == 0 {
+			label = strings.TrimSuffix(name, ".md")
+		}
+		g.Nodes = append(g.Nodes, graphNode{
+			ID:        noteNodeId(name),
+			Kind:      "note",
+			Label:     label,
+			File:      name,
+			CreatedAt: &created,
+		})
+		for _, link := range slicez.Uniq(n.links) {
+			target, ok := resolver.resolve(link)
+			if !ok {
+				continue
+			}
+			g.Edges = append(g.Edges, graphEdge{Source: noteNodeId(name), Target: noteNodeId(target), Kind: "link"})
+		}
+	}
+
+	for _, tag := range slicez.Sort(mapz.Keys(index.TagsToId)) {
+		var members []string
+		for _, id := range index.TagsToId[tag] {
+			name := index.IdToName[id]
+			if resolver.names[name] {
+				members = append(members, name)
+			}
+		}
+		if len(members) == 0 {
+			continue
+		}
+		g.Nodes = append(g.Nodes, graphNode{ID: tagNodeId(tag), Kind: "tag", Label: "#" + tag})
+		for _, name := range slicez.Uniq(members) {
+			g.Edges = append(g.Edges, graphEdge{Source: noteNodeId(name), Target: tagNodeId(tag), Kind: "tag"})
+		}
+	}
+	return g, nil
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+func writeDot(w io.Writer, g noteGraph) error {
+	var b strings.Builder
+	b.WriteString("digraph mark {\n")
+	for _, n := range g.Nodes {
+		switch n.Kind {
+		case "note":
+			fmt.Fprintf(&b, "  %s [label=%s shape=box created=%s];\n", dotQuote(n.ID), dotQuote(n.Label), dotQuote(n.CreatedAt.Format(time.RFC3339)))
+		default:
+			fmt.Fprintf(&b, "  %s [label=%s shape=ellipse];\n", dotQuote(n.ID), dotQuote(n.Label))
+		}
+	}
+	for _, e := range g.Edges {
+		style := "solid"
+		if e.Kind == "tag" {
+			style = "dashed"
+		}
+		fmt.Fprintf(&b, "  %s -> %s [kind=%s style=%s];\n", dotQuote(e.Source), dotQuote(e.Target), dotQuote(e.Kind), style)
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+type graphmlKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+type graphmlData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+type graphmlNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphmlData `xml:"data"`
+}
+type graphmlEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphmlData `xml:"data"`
+}
+type graphml struct {
+	XMLName xml.Name     `xml:"graphml"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Keys    []graphmlKey `xml:"key"`
+	Graph   struct {
+		ID          string        `xml:"id,attr"`
+		EdgeDefault string        `xml:"edgedefault,attr"`
+		Nodes       []graphmlNode `xml:"node"`
+		Edges       []graphmlEdge `xml:"edge"`
+	} `xml:"graph"`
+}
+
+func writeGraphML(w io.Writer, g noteGraph) error {
+	doc := graphml{
+		Xmlns: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphmlKey{
+			{ID: "kind", For: "node", Name: "kind", Type: "string"},
+			{ID: "label", For: "node", Name: "label", Type: "string"},
+			{ID: "created_at", For: "node", Name: "created_at", Type: "string"},
+			{ID: "edge_kind", For: "edge", Name: "kind", Type: "string"},
+		},
+	}
+	doc.Graph.ID = "mark"
+	doc.Graph.EdgeDefault = "directed"
+	for _, n := range g.Nodes {
+		node := graphmlNode{ID: n.ID, Data: []graphmlData{{Key: "kind", Value: n.Kind}, {Key: "label", Value: n.Label}}}
+		if n.CreatedAt != nil {
+			node.Data = append(node.Data, graphmlData{Key: "created_at", Value: n.CreatedAt.Format(time.RFC3339)})
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, node)
+	}
+	for _, e := range g.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphmlEdge{Source: e.Source, Target: e.Target, Data: []graphmlData{{Key: "edge_kind", Value: e.Kind}}})
+	}
+
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	err = enc.Encode(doc)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+func graph(c *cli.Context, cfg config.Config) error {
+	g, err := buildGraph()
+	if err != nil {
+		return err
+	}
+	switch c.String("format") {
+	case "", "dot":
+		return writeDot(os.Stdout, g)
+	case "json":
+		return json.NewEncoder(os.Stdout).Encode(g)
+	case "graphml":
+		return writeGraphML(os.Stdout, g)
+	default:
+		return fmt.Errorf("unknown graph format %s, expected dot, json or graphml", c.String("format"))
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestBuildGraph(t *testing.T) {
+	todo, groceries, shopping, _ := saveLinkedNotes(t)
+
+	g, err := buildGraph()
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes := map[string]graphNode{}
+	for _, n := range g.Nodes {
+		nodes[n.ID] = n
+	}
+	if len(g.Nodes) != 5 || nodes[noteNodeId(path.Base(todo))].Label != "Todo" || nodes[tagNodeId("home")].Label != "#home" {
+		t.Fatalf("expected 4 notes and the tag home, got %+v", g.Nodes)
+	}
+
+	edges := map[graphEdge]bool{}
+	for _, e := range g.Edges {
+		edges[e] = true
+	}
+	for _, e := range []graphEdge{
+		{Source: noteNodeId(path.Base(todo)), Target: noteNodeId(path.Base(groceries)), Kind: "link"},
+		{Source: noteNodeId(path.Base(groceries)), Target: noteNodeId(path.Base(todo)), Kind: "link"},
+		{Source: noteNodeId(path.Base(todo)), Target: tagNodeId("home"), Kind: "tag"},
+		{Source: noteNodeId(path.Base(shopping)), Target: tagNodeId("home"), Kind: "tag"},
+	} {
+		if !edges[e] {
+			t.Fatalf("expected the edge %+v, got %+v", e, g.Edges)
+		}
+	}
+	// the broken link to missing is not an edge
+	if len(g.Edges) != 4 {
+		t.Fatalf("expected 4 edges, got %+v", g.Edges)
+	}
+}
+
+func TestWriteGraph(t *testing.T) {
+	todo, groceries, _, _ := saveLinkedNotes(t)
+	g, err := buildGraph()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dot bytes.Buffer
+	err = writeDot(&dot, g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		`  "note:` + path.Base(todo) + `" [label="Todo" shape=box created="2022-08-12T14:04:49Z"];`,
+		`  "tag:home" [label="#home" shape=ellipse];`,
+		`  "note:` + path.Base(todo) + `" -> "note:` + path.Base(groceries) + `" [kind="link" style=solid];`,
+		`  "note:` + path.Base(todo) + `" -> "tag:home" [kind="tag" style=dashed];`,
+	} {
+		if !strings.Contains(dot.String(), line+"\n") {
+			t.Fatalf("expected the dot output to contain\n%s\ngot\n%s", line, dot.String())
+		}
+	}
+	if !strings.HasPrefix(dot.String(), "digraph mark {\n") || !strings.HasSuffix(dot.String(), "}\n") {
+		t.Fatalf("expected a digraph, got\n%s", dot.String())
+	}
+
+	var out bytes.Buffer
+	err = writeGraphML(&out, g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc graphml
+	err = xml.Unmarshal(out.Bytes(), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Graph.Nodes) != len(g.Nodes) || len(doc.Graph.Edges) != len(g.Edges) || doc.Graph.EdgeDefault != "directed" {
+		t.Fatalf("expected the nodes and edges of the graph, got %+v", doc.Graph)
+	}
+	node := doc.Graph.Nodes[0]
+	if node.ID != noteNodeId(path.Base(todo)) || len(node.Data) != 3 || node.Data[1].Value != "Todo" {
+		t.Fatalf("expected the first node to be the todo note, got %+v", node)
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/crholm/mark"
+	"github.com/crholm/mark/internal/config"
+	"github.com/crholm/mark/internal/diff"
+	"github.com/crholm/mark/internal/fss"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/term"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// gitPath returns the path of a note relative to the storage dir, as given to git
+func gitPath(name string) (string, error) {
+	if _, ok := store.(*fss.FS); !ok {
+		return "", errors.New("the history of notes is only kept for a notebook stored in a git repo")
+	}
+	return path.Join("lib", name), nil
+}
+
+// historyNote returns the name of a note given as a filename, even if it has been removed, or found by a search
+func historyNote(query string, cfg config.Config) (string, error) {
+	if name, ok := existingName(query); ok {
+		return name, nil
+	}
+	for _, filename := range []string{query, query + ".md"} {
+		if name, err := fss.GetFilenameToName(filename); err == nil {
+			return name, nil
+		}
+	}
+	return findNote(query, cfg)
+}
+
+// gitShow returns a note as it was in a revision
+func gitShow(name string, rev string) ([]byte, error) {
+	p, err := gitPath(name)
+	if err != nil {
+		return nil, err
+	}
+	r, err := storageRepo()
+	if err != nil {
+		return nil, err
+	}
+	return r.Show(rev, p)
+}
+
+func history(c *cli.Context, cfg config.Config) error {
+	name, err := historyNote(c.Args().First(), cfg)
+	if err != nil {
+		return err
+	}
+	p, err := gitPath(name)
+	if err != nil {
+		return err
+	}
+	r, err := storageRepo()
+	if err != nil {
+		return err
+	}
+	commits, err := r.Log(p)
+	if err != nil {
+		return err
+	}
+	if len(commits) == 0 {
+		fmt.Println("no commits of", name)
+		return nil
+	}
+	for _, commit := range commits {
+		fmt.Printf("%s\t%s\t%s\t%s\n", commit.Hash, commit.Date.In(time.Local).Format("2006-01-02 15:04"), commit.Author, commit.Subject)
+	}
+	return nil
+}
+
+func diffCmd(c *cli.Context, cfg config.Config) error {
+	name, err := historyNote(c.Args().First(), cfg)
+	if err != nil {
+		return err
+	}
+	rev := c.Args().Get(1)
+	if len(rev) == 0 {
+		rev = "HEAD"
+	}
+
+	old, err := gitShow(name, rev)
+	if err != nil {
+		return err
+	}
+	oldHeader, oldContent, err := versionOf(old)
+	if err != nil {
+		return fmt.Errorf("%s of %s: %w", rev, name, err)
+	}
+	// a removed note is diffed as empty
+	var header mark.Header
+	var content []byte
+	current, err := store.Get(name)
+	if err == nil {
+		header, content, err = versionOf(current)
+	}
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	color := term.IsTerminal(int(os.Stdout.Fd()))
+	fmt.Printf("--- %s:%s\n+++ %s\n", rev, name, name)
+	oldFields, fields := headerFields(oldHeader), headerFields(header)
+	for i, f := range fields {
+		if f.value == oldFields[i].value {
+			continue
+		}
+		fmt.Println(paint(color, ansiRed, fmt.Sprintf("- %s: %s", f.name, oldFields[i].value)))
+		fmt.Println(paint(color, ansiGreen, fmt.Sprintf("+ %s: %s", f.name, f.value)))
+	}
+
+	edits := diff.WordDiff(string(oldContent), string(content))
+	if !diff.Changed(edits) {
+		return nil
+	}
+	fmt.Println()
+	for _, e := range edits {
+		switch e.Op {
+		case diff.Delete:
+			fmt.Print(paint(color, ansiRed, "[-"+e.Text+"-]"))
+		case diff.Insert:
+			fmt.Print(paint(color, ansiGreen, "{+"+e.Text+"+}"))
+		default:
+			fmt.Print(e.Text)
+		}
+	}
+	fmt.Println()
+	return nil
+}
+
+func restore(c *cli.Context, cfg config.Config) error {
+	if c.NArg() < 2 {
+		return errors.New("a note and the revision to restore it to must be specified, e.g. mark restore <note> HEAD~2")
+	}
+	name, err := historyNote(c.Args().First(), cfg)
+	if err != nil {
+		return err
+	}
+	rev := c.Args().Get(1)
+	data, err := gitShow(name, rev)
+	if err != nil {
+		return err
+	}
+	_, _, err = mark.UnmarshalNote(data)
+	if err != nil {
+		return fmt.Errorf("%s of %s: %w", rev, name, err)
+	}
+	err = store.Put(name, data)
+	if err != nil {
+		return err
+	}
+	fmt.Println("restored", name, "to", rev)
+	err = updateIndex(name, cfg)
+	if err != nil {
+		return err
+	}
+	autoCommit(name, "restore", cfg)
+	return nil
+}
+
+// versionOf reads a version of a note, decrypting its content if it is encrypted
+func versionOf(data []byte) (mark.Header, []byte, error) {
+	header, content, err := mark.UnmarshalNote(data)
+	if err != nil {
+		return header, nil, err
+	}
+	content, err = decryptNote(header, content)
+	return header, content, err
+}
+
+type headerField struct {
+	name  string
+	value string
+}
+
+// headerFields returns the fields of a header as they are compared by mark diff
+func headerFields(h mark.Header) []headerField {
+	timestamp := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+	var attachments []string
+	for _, a := range h.Attachments {
+		attachments = append(attachments, a.Name)
+	}
+	extra := ""
+	if len(h.Extra) > 0 {
+		extra = fmt.Sprint(h.Extra)
+	}
+	return []headerField{
+		{"title", h.Title},
+		{"alias", h.Alias},
+		{"tags", strings.Join(h.Tags, ", ")},
+		{"kind", h.Kind},
+		{"encrypted", fmt.Sprint(h.Encrypted)},
+		{"attachments", strings.Join(attachments, ", ")},
+		{"created_at", timestamp(h.CreatedAt)},
+		{"updated_at", timestamp(h.UpdatedAt)},
+		{"extra", extra},
+	}
+}
+
+const ansiRed = "\x1b[31m"
+const ansiGreen = "\x1b[32m"
+
+func paint(color bool, ansi string, text string) string {
+	if !color {
+		return text
+	}
+	return ansi + text + "\x1b[0m"
+}
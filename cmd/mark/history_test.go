@@ -0,0 +1,86 @@
+package main
+
+import (
+	"github.com/crholm/mark/internal/config"
+	"github.com/crholm/mark/internal/fss"
+	"github.com/crholm/mark/internal/git"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistory(t *testing.T) {
+	testGit(t)
+	r := git.New(t.TempDir())
+	_, err := r.Run("init", "--quiet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fss.SetStoragePath(r.Dir)
+	store = fss.NewFS(r.Dir)
+	cfg := config.Default()
+	commit := func(name string, message string) {
+		t.Helper()
+		_, err := r.Run("add", path.Join("lib", name))
+		if err == nil {
+			err = r.Commit(message)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	groceries := saveNote(t, time.Date(2022, 8, 12, 14, 4, 49, 0, time.UTC), "Groceries", "Buy milk #home")
+	filename := path.Base(groceries)
+	commit(groceries, "add groceries")
+	err = appendNote(groceries, []byte("and bread #weekend"), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit(groceries, "more groceries")
+
+	out := stdoutOf(t, func() error { return history(contextOf(t, filename), cfg) })
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], "\tmore groceries") || !strings.HasSuffix(lines[1], "\tadd groceries") {
+		t.Fatalf("expected the commits of the note, latest first, got %q", out)
+	}
+
+	out = stdoutOf(t, func() error { return diffCmd(contextOf(t, filename, "HEAD~1"), cfg) })
+	if !strings.Contains(out, "and bread #weekend+}") {
+		t.Fatalf("expected the appended text to be inserted, got %q", out)
+	}
+
+	autocommit := cfg
+	autocommit.Sync.Autocommit = true
+	stdoutOf(t, func() error { return restore(contextOf(t, filename, "HEAD~1"), autocommit) })
+	_, content, err := readNote(groceries)
+	if err != nil || string(content) != "Buy milk #home" {
+		t.Fatalf("expected the note to be restored, got %q, %v", content, err)
+	}
+	head, err := r.Head()
+	if err != nil || head.Subject != "restore: Groceries" {
+		t.Fatalf("expected the restore to be autocommitted, got %+v, %v", head, err)
+	}
+	found, err := ls(":weekend")
+	if err != nil || len(found) != 0 {
+		t.Fatalf("expected the tags in the index to be updated by restoring, got %v, %v", found, err)
+	}
+
+	// a removed note is diffed as empty and can be restored
+	err = store.Delete(groceries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit(groceries, "rm groceries")
+	out = stdoutOf(t, func() error { return diffCmd(contextOf(t, filename, "HEAD~1"), cfg) })
+	if !strings.Contains(out, "- title: Groceries") || !strings.Contains(out, "[-Buy milk #home") {
+		t.Fatalf("expected the removed note to be diffed as deleted, got %q", out)
+	}
+	stdoutOf(t, func() error { return restore(contextOf(t, filename, "HEAD~2"), cfg) })
+	found, err = ls(":weekend")
+	if err != nil || !reflect.DeepEqual(found, []string{groceries}) {
+		t.Fatalf("expected the restored note to be indexed, got %v, %v", found, err)
+	}
+}
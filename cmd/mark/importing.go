@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/crholm/mark"
+	"github.com/crholm/mark/internal/config"
+	"github.com/crholm/mark/internal/importer"
+	"github.com/crholm/mark/internal/ts"
+	"github.com/urfave/cli/v2"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// withFrontMatter merges yaml front matter present in data into meta. Values already set in meta, i.e. a title
+// given on the command line, take precedence. Tags of the front matter are kept by adding them to the content
+func withFrontMatter(meta mark.Header, data []byte) (mark.Header, []byte, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte("---")) {
+		return meta, bytes.TrimSpace(data), nil
+	}
+	input, content, err := mark.UnmarshalNote(data)
+	if err != nil {
+		return meta, nil, fmt.Errorf("could not parse front matter: %w", err)
+	}
+
+	if len(meta.Title) == 0 {
+		meta.Title = input.Title
+	}
+	if len(meta.Alias) == 0 {
+		meta.Alias = input.Alias
+	}
+	if len(meta.Kind) == 0 {
+		meta.Kind = input.Kind
+	}
+	if !input.CreatedAt.IsZero() {
+		meta.CreatedAt = input.CreatedAt
+	}
+	if !input.UpdatedAt.IsZero() {
+		meta.UpdatedAt = input.UpdatedAt
+	}
+	meta.Extra = input.Extra
+	return meta, ts.EnsureTags(content, input.Tags), nil
+}
+
+func importFile(file string, cfg config.Config) (string, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	meta, content, err := withFrontMatter(mark.Header{CreatedAt: now, UpdatedAt: now}, data)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", file, err)
+	}
+	if len(meta.Title) == 0 {
+		meta.Title = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	}
+	meta.Tags = ts.GetTagsFromNote(content)
+	meta.Encrypted = cfg.Encrypt
+
+	name, _, err := saveNewNote(meta, content)
+	if err != nil {
+		return "", err
+	}
+	return name, updateIndex(name, cfg)
+}
+
+func importFiles(c *cli.Context, cfg config.Config) error {
+	if c.Args().Len() == 0 {
+		return errors.New("at least one file to import must be specified")
+	}
+	for _, file := range c.Args().Slice() {
+		name, err := importFile(file, cfg)
+		if err != nil {
+			return err
+		}
+		fmt.Println(file, "->", path.Base(name))
+	}
+	return nil
+}
+
+func importNotes(c *cli.Context, cfg config.Config) error {
+	src := c.Args().First()
+	if len(src) == 0 {
+		return errors.New("a path to import from must be specified")
+	}
+	from, err := importer.Of(c.String("from"))
+	if err != nil {
+		return err
+	}
+	notes, err := from(src)
+	if err != nil {
+		return err
+	}
+
+	for _, n := range notes {
+		n.Header.Encrypted = cfg.Encrypt
+		name, _, err := saveNewNote(n.Header, n.Content)
+		if err != nil {
+			return err
+		}
+		fmt.Println(n.Source, "->", path.Base(name))
+	}
+
+	fmt.Printf("imported %d notes, reindexing\n", len(notes))
+	return reindexAll(cfg)
+}
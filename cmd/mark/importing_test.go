@@ -0,0 +1,85 @@
+package main
+
+import (
+	"github.com/crholm/mark"
+	"github.com/crholm/mark/internal/config"
+	"github.com/crholm/mark/internal/fss"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWithFrontMatter(t *testing.T) {
+	now := time.Date(2022, 8, 12, 14, 4, 49, 0, time.UTC)
+	created := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, c := range []struct {
+		meta     mark.Header
+		data     string
+		expected mark.Header
+		content  string
+	}{
+		{
+			mark.Header{CreatedAt: now, UpdatedAt: now},
+			"\n  Just content  \n",
+			mark.Header{CreatedAt: now, UpdatedAt: now},
+			"Just content",
+		},
+		{
+			mark.Header{CreatedAt: now, UpdatedAt: now},
+			"---\ntitle: Imported\nalias: imp\ntags: [home, work]\ncreated_at: 2021-01-01T00:00:00Z\nsource: obsidian\n---\nContent #home\n",
+			mark.Header{Title: "Imported", Alias: "imp", CreatedAt: created, UpdatedAt: now, Extra: map[string]interface{}{"source": "obsidian"}},
+			"Content #home\n\n#work",
+		},
+		{
+			// the title given on the command line takes precedence
+			mark.Header{Title: "Given", CreatedAt: now, UpdatedAt: now},
+			"---\ntitle: Imported\n---\nContent",
+			mark.Header{Title: "Given", CreatedAt: now, UpdatedAt: now},
+			"Content",
+		},
+	} {
+		meta, content, err := withFrontMatter(c.meta, []byte(c.data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(meta, c.expected) || string(content) != c.content {
+			t.Fatalf("expected %+v and %q, got %+v and %q", c.expected, c.content, meta, content)
+		}
+	}
+
+	_, _, err := withFrontMatter(mark.Header{}, []byte("---\ntitle: [broken\n---\nContent"))
+	if err == nil {
+		t.Fatal("expected an error for broken front matter")
+	}
+}
+
+func TestImportFile(t *testing.T) {
+	store = fss.NewMemory()
+	file := filepath.Join(t.TempDir(), "Meeting notes.md")
+	err := os.WriteFile(file, []byte("---\ntags: [work]\ncreated_at: 2022-08-12T14:04:49Z\n---\nAbout kubernetes"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name, err := importFile(file, config.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "2022/08/2022-08-12_14:04:49.000Z_Friday.md" {
+		t.Fatalf("expected the note to be named by the time of creation in the front matter, got %s", name)
+	}
+	meta, content, err := readNote(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Title != "Meeting notes" || !reflect.DeepEqual(meta.Tags, []string{"work"}) || string(content) != "About kubernetes\n\n#work" {
+		t.Fatalf("expected the note to be titled by the filename and tagged, got %+v and %q", meta, content)
+	}
+	files, err := ls(":work")
+	if err != nil || !reflect.DeepEqual(files, []string{name}) {
+		t.Fatalf("expected the imported note to be indexed, got %v, %v", files, err)
+	}
+}
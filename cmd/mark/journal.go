@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"github.com/crholm/mark"
+	"github.com/crholm/mark/internal/config"
+	"github.com/crholm/mark/internal/fss"
+	"github.com/urfave/cli/v2"
+	"path"
+	"strings"
+	"time"
+)
+
+// findJournal returns the journal note of the local date of now, if there is one
+func findJournal(now time.Time) (string, bool, error) {
+	files, err := ls("")
+	if err != nil {
+		return "", false, err
+	}
+
+	year, month, day := now.Date()
+	start := time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+
+	for _, f := range files { // newest first
+		created, err := fss.GetFilenameTimestamp(path.Base(f))
+		if err != nil {
+			continue
+		}
+		if created.Before(start) {
+			break
+		}
+		meta, err := readHeader(f)
+		if err != nil {
+			return "", false, err
+		}
+		y, m, d := meta.CreatedAt.In(now.Location()).Date()
+		if meta.Kind == mark.KindJournal && y == year && m == month && d == day {
+			return f, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+func today(c *cli.Context, cfg config.Config) error {
+	now := time.Now()
+
+	file, found, err := findJournal(now)
+	if err != nil {
+		return err
+	}
+	if !found {
+		meta := mark.Header{
+			Title:     now.Format("Monday Jan 02 2006"),
+			Kind:      mark.KindJournal,
+			CreatedAt: now,
+			UpdatedAt: now,
+			Encrypted: cfg.Encrypt,
+		}
+		file, _, err = saveNewNote(meta, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	args := c.Args().Slice()
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		defer updateIndex(file, cfg)
+		return doEdit(file, cfg)
+	}
+
+	bullet := fmt.Sprintf("- %s %s", now.Format("15:04"), strings.Join(args, " "))
+	return appendNote(file, []byte(bullet), cfg)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"github.com/crholm/mark"
+	"github.com/crholm/mark/internal/config"
+	"github.com/crholm/mark/internal/fss"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestToday(t *testing.T) {
+	store = fss.NewMemory()
+	cfg := config.Default()
+	now := time.Now()
+
+	// a note of today, which is not a journal
+	saveNote(t, now.Add(-time.Second), "Groceries", "Buy milk")
+	_, found, err := findJournal(now)
+	if err != nil || found {
+		t.Fatalf("expected no journal, got %v, %v", found, err)
+	}
+
+	for _, text := range []string{"stand up", "lunch #food"} {
+		err = today(contextOf(t, "--", text), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	name, found, err := findJournal(now)
+	if err != nil || !found {
+		t.Fatalf("expected the journal of today, got %v, %v", found, err)
+	}
+	meta, content, err := readNote(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Kind != mark.KindJournal || meta.Title != now.Format("Monday Jan 02 2006") {
+		t.Fatalf("expected a journal note titled by the date, got %+v", meta)
+	}
+	lines := strings.Split(string(content), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], " stand up") || !strings.HasSuffix(lines[1], " lunch #food") ||
+		!strings.HasPrefix(lines[0], "- ") {
+		t.Fatalf("expected a bullet per text appended, got %q", content)
+	}
+	if len(meta.Tags) != 1 || meta.Tags[0] != "food" {
+		t.Fatalf("expected the tags of the bullets, got %v", meta.Tags)
+	}
+
+	// the journal of another day is a note of its own
+	_, found, err = findJournal(now.AddDate(0, 0, 2))
+	if err != nil || found {
+		t.Fatalf("expected no journal of the day after tomorrow, got %v, %v", found, err)
+	}
+	names, err := store.List()
+	if err != nil || len(names) != 2 {
+		t.Fatalf("expected the note and the journal, got %v, %v", names, err)
+	}
+}
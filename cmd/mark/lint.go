@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/crholm/mark"
+	"github.com/crholm/mark/internal/config"
+	"github.com/crholm/mark/internal/ts"
+	"github.com/modfin/henry/mapz"
+	"github.com/modfin/henry/slicez"
+	"github.com/urfave/cli/v2"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type brokenLink struct {
+	File string `json:"file"`
+	Link string `json:"link"`
+}
+
+type lintReport struct {
+	BrokenLinks      []brokenLink        `json:"broken_links"`
+	Orphans          []string            `json:"orphans"`
+	DuplicateAliases map[string][]string `json:"duplicate_aliases"`
+}
+
+func (r lintReport) problems() int {
+	return len(r.BrokenLinks) + len(r.Orphans) + len(r.DuplicateAliases)
+}
+
+type linkedNote struct {
+	file   string
+	header mark.Header
+	links  []string
+}
+
+// walkNotes reads every note of the store, regardless of it being stored in the expected year/month dir. Encrypted
+// notes are decrypted to find their links
+func walkNotes() ([]linkedNote, error) {
+	var notes []linkedNote
+	err := store.Walk(func(name string, data []byte) error {
+		header, content, err := mark.UnmarshalNote(data)
+		if err == nil {
+			content, err = decryptNote(header, content)
+		}
+		if err != nil {
+			return fmt.Errorf("could not read note %s: %w", name, err)
+		}
+		notes = append(notes, linkedNote{
+			file:   name,
+			header: header,
+			links:  ts.GetLinksFromNote(content),
+		})
+		return nil
+	})
+	return notes, err
+}
+
+// linkResolver resolves link targets, i.e. an alias or a filename with or without .md, to the filename of a note
+type linkResolver struct {
+	aliases map[string][]string
+	names   map[string]bool
+}
+
+func newLinkResolver(notes []linkedNote, index mark.Index) linkResolver {
+	r := linkResolver{
+		aliases: map[string][]string{},
+		names:   map[string]bool{},
+	}
+	onDisk := map[string]bool{}
+	for _, n := range notes {
+		onDisk[filepath.Base(n.file)] = true
+	}
+	for _, name := range index.IdToName {
+		if onDisk[name] {
+			r.names[name] = true
+		}
+	}
+	// without an index, e.g. before the first mark reindex, the names on disk are the notes
+	if len(index.IdToName) == 0 {
+		r.names = onDisk
+	}
+	for _, n := range notes {
+		alias := strings.TrimSpace(n.header.Alias)
+		if len(alias) == 0 {
+			continue
+		}
+		r.aliases[alias] = append(r.aliases[alias], filepath.Base(n.file))
+	}
+	return r
+}
+
+func (r linkResolver) resolve(link string) (string, bool) {
+	if names, ok := r.aliases[link]; ok && len(names) > 0 {
+		return names[0], r.names[names[0]]
+	}
+	name := link
+	if filepath.Ext(name) != ".md" {
+		name = name + ".md"
+	}
+	return name, r.names[name]
+}
+
+// lintNotes finds the broken links, orphaned notes and aliases shared by several notes
+func lintNotes() (lintReport, error) {
+	notes, err := walkNotes()
+	if err != nil {
+		return lintReport{}, err
+	}
+	index, err := readIndex()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return lintReport{}, err
+	}
+
+	resolver := newLinkResolver(notes, index)
+	report := lintReport{
+		BrokenLinks:      []brokenLink{},
+		Orphans:          []string{},
+		DuplicateAliases: map[string][]string{},
+	}
+
+	inbound := map[string]int{}
+	for _, n := range notes {
+		for _, link := range n.links {
+			name, ok := resolver.resolve(link)
+			if !ok {
+				report.BrokenLinks = append(report.BrokenLinks, brokenLink{File: filepath.Base(n.file), Link: link})
+				continue
+			}
+			if name != filepath.Base(n.file) {
+				inbound[name]++
+			}
+		}
+	}
+
+	for _, n := range notes {
+		name := filepath.Base(n.file)
+		if len(n.header.Tags) == 0 && len(strings.TrimSpace(n.header.Title)) == 0 && inbound[name] == 0 {
+			report.Orphans = append(report.Orphans, name)
+		}
+	}
+
+	for alias, names := range resolver.aliases {
+		if len(names) > 1 {
+			report.DuplicateAliases[alias] = slicez.Sort(names)
+		}
+	}
+	return report, nil
+}
+
+func lint(c *cli.Context, cfg config.Config) error {
+	report, err := lintNotes()
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("json") {
+		err = json.NewEncoder(os.Stdout).Encode(report)
+		if err != nil {
+			return err
+		}
+	} else {
+		for _, l := range report.BrokenLinks {
+			fmt.Printf("broken link      %s -> [[%s]]\n", l.File, l.Link)
+		}
+		for _, o := range report.Orphans {
+			fmt.Printf("orphan           %s\n", o)
+		}
+		for _, alias := range slicez.Sort(mapz.Keys(report.DuplicateAliases)) {
+			fmt.Printf("duplicate alias  %s: %s\n", alias, strings.Join(report.DuplicateAliases[alias], ", "))
+		}
+	}
+
+	if report.problems() > 0 {
+		return cli.Exit("", 1)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"github.com/crholm/mark"
+	"github.com/crholm/mark/internal/fss"
+	"path"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// saveLinkedNotes saves notes linking to each other, along with one orphan and an alias used by two notes
+func saveLinkedNotes(t *testing.T) (todo string, groceries string, shopping string, orphan string) {
+	t.Helper()
+	store = fss.NewMemory()
+	todo = saveNote(t, time.Date(2022, 8, 12, 14, 4, 49, 0, time.UTC), "Todo", "Buy [[groceries|food]] and read [[missing]] #home")
+	groceries = saveNote(t, time.Date(2022, 8, 13, 10, 0, 0, 0, time.UTC), "Groceries", "Milk, see [[Todo]]")
+	shopping = saveNote(t, time.Date(2022, 8, 14, 10, 0, 0, 0, time.UTC), "Shopping", "Cheese #home")
+	orphan = saveNote(t, time.Date(2022, 8, 15, 10, 0, 0, 0, time.UTC), "", "nothing to see")
+	for _, n := range []struct{ name, alias string }{{groceries, "groceries"}, {shopping, "groceries"}, {todo, "Todo"}} {
+		meta, content, err := readNote(n.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		meta.Alias = n.alias
+		data, err := mark.MarshalNote(meta, content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = store.Put(n.name, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return todo, groceries, shopping, orphan
+}
+
+func TestLint(t *testing.T) {
+	todo, groceries, shopping, orphan := saveLinkedNotes(t)
+
+	report, err := lintNotes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := lintReport{
+		BrokenLinks:      []brokenLink{{File: path.Base(todo), Link: "missing"}},
+		Orphans:          []string{path.Base(orphan)},
+		DuplicateAliases: map[string][]string{"groceries": {path.Base(groceries), path.Base(shopping)}},
+	}
+	if !reflect.DeepEqual(report, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, report)
+	}
+	if report.problems() != 3 {
+		t.Fatalf("expected 3 problems, got %d", report.problems())
+	}
+}
+
+func TestLinkResolver(t *testing.T) {
+	todo, groceries, _, _ := saveLinkedNotes(t)
+	notes, err := walkNotes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, err := readIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newLinkResolver(notes, index)
+
+	for link, expected := range map[string]string{
+		"Todo":          path.Base(todo),
+		"groceries":     path.Base(groceries),
+		path.Base(todo): path.Base(todo),
+		path.Base(groceries)[:len(path.Base(groceries))-3]: path.Base(groceries),
+	} {
+		name, ok := r.resolve(link)
+		if !ok || name != expected {
+			t.Fatalf("expected %s to resolve to %s, got %s, %v", link, expected, name, ok)
+		}
+	}
+	if _, ok := r.resolve("missing"); ok {
+		t.Fatal("expected a link to a missing note to not resolve")
+	}
+}
+
+func TestLinkResolverWithoutIndex(t *testing.T) {
+	todo, groceries, _, _ := saveLinkedNotes(t)
+	notes, err := walkNotes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newLinkResolver(notes, mark.NewIndex())
+
+	for link, expected := range map[string]string{
+		"Todo":               path.Base(todo),
+		path.Base(groceries): path.Base(groceries),
+	} {
+		name, ok := r.resolve(link)
+		if !ok || name != expected {
+			t.Fatalf("expected %s to resolve to %s without an index, got %s, %v", link, expected, name, ok)
+		}
+	}
+	if _, ok := r.resolve("missing"); ok {
+		t.Fatal("expected a link to a missing note to not resolve")
+	}
+}
@@ -7,8 +7,10 @@ import (
 	"errors"
 	"fmt"
 	"github.com/crholm/mark"
+	"github.com/crholm/mark/internal/config"
 	"github.com/crholm/mark/internal/fss"
 	"github.com/crholm/mark/internal/printer"
+	"github.com/crholm/mark/internal/tmpl"
 	"github.com/crholm/mark/internal/ts"
 	"github.com/crholm/mark/internal/tsar"
 	"github.com/mattn/go-shellwords"
@@ -16,19 +18,22 @@ import (
 	"github.com/modfin/henry/mapz"
 	"github.com/modfin/henry/slicez"
 	"github.com/urfave/cli/v2"
+	"golang.org/x/term"
 	"gopkg.in/yaml.v3"
 	"io"
+	"io/fs"
 	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
-	"path/filepath"
+	"path"
 	"regexp"
 	"strings"
 	"time"
 )
 
-var grep bool
+// store is where the notes and indexes of the current notebook are kept
+var store fss.Store
 
 func main() {
 
@@ -59,14 +64,20 @@ SHORT HAND:
 						Name:    "pick",
 						Aliases: []string{"p"},
 					},
+					&cli.IntFlag{
+						Usage:   "prints only the lines matching the search, with `N` lines of context around them",
+						Name:    "context",
+						Aliases: []string{"C"},
+					},
 				},
-				Action: func(c *cli.Context) error {
+				Action: withConfig(func(c *cli.Context, cfg config.Config) error {
 					prefix := c.Args().First()
 
-					files, err := ls(prefix)
+					files, found, err := search(c, prefix)
 					if err != nil {
 						return err
 					}
+					store = found
 
 					if len(files) == 0 {
 						fmt.Println("no entries")
@@ -74,15 +85,19 @@ SHORT HAND:
 					}
 
 					if c.Bool("pick") {
-						file, err := pickFile(files)
+						file, err := pickFile(files, cfg)
 						if err != nil {
 							return err
 						}
 						files = []string{file}
 					}
 
-					return page(files, printer.Of(c.String("format")))
-				},
+					p, err := printerOf(c, cfg)
+					if err != nil {
+						return err
+					}
+					return page(files, p, cfg)
+				}),
 			},
 			{
 				Name:      "cat",
@@ -94,31 +109,44 @@ SHORT HAND:
 						Name:    "pick",
 						Aliases: []string{"p"},
 					},
+					&cli.IntFlag{
+						Usage:   "prints only the lines matching the search, with `N` lines of context around them",
+						Name:    "context",
+						Aliases: []string{"C"},
+					},
 				},
-				Action: func(c *cli.Context) error {
+				Action: withConfig(func(c *cli.Context, cfg config.Config) error {
 					prefix := c.Args().First()
 
-					files, err := ls(prefix)
+					files, found, err := search(c, prefix)
 					if err != nil {
 						return err
 					}
+					store = found
 
-					if len(files) == 0 {
+					if len(files) == 0 && !jsonOutput(c) {
 						fmt.Println("no entries")
 						return nil
 					}
 
-					if c.Bool("pick") {
-						file, err := pickFile(files)
+					if c.Bool("pick") && len(files) > 0 {
+						file, err := pickFile(files, cfg)
 						if err != nil {
 							return err
 						}
 						files = []string{file}
 					}
 
-					_, err = io.Copy(os.Stdout, cat(files, printer.Of(c.String("format"))))
+					if jsonOutput(c) {
+						return writeNotesJSON(os.Stdout, files, true)
+					}
+					p, err := printerOf(c, cfg)
+					if err != nil {
+						return err
+					}
+					_, err = io.Copy(os.Stdout, cat(files, p))
 					return err
-				},
+				}),
 			},
 			{
 				Name:      "ls",
@@ -130,41 +158,45 @@ SHORT HAND:
 						Name:  "v",
 					},
 				},
-				Action: func(c *cli.Context) error {
+				Action: withConfig(func(c *cli.Context, cfg config.Config) error {
 					return clils(c, c.Bool("v"))
-				},
+				}),
 			},
 			{
 				Name:      "ll",
 				ArgsUsage: "[file | :tag | free text search]",
 				Usage:     "list notes in a more verbose way, including tile and tags, shorthand for `mark ls -v`",
-				Action: func(c *cli.Context) error {
+				Action: withConfig(func(c *cli.Context, cfg config.Config) error {
 					return clils(c, true)
-				},
+				}),
 			},
 			{
 				Name:      "rm",
 				ArgsUsage: "[file | :tag | free text search]",
 				Usage:     "removes a note",
-				Action: func(c *cli.Context) error {
+				Action: withConfig(func(c *cli.Context, cfg config.Config) error {
 					filename := c.Args().First()
 
-					file, err := fss.GetFilenameToPath(filename)
-					var files []string
-					if err != nil {
-						files, err = ls(filename)
+					name, ok := existingName(filename)
+					if !ok {
+						files, err := ls(filename)
 						if err != nil {
 							return err
 						}
-						file, err = pickFile(files)
+						name, err = pickFile(files, cfg)
 						if err != nil {
 							return err
 						}
 					}
 
-					fmt.Println("rm", file)
-					return os.Remove(file)
-				},
+					fmt.Println("rm", name)
+					err := store.Delete(name)
+					if err != nil {
+						return err
+					}
+					autoCommit(name, "rm", cfg)
+					return nil
+				}),
 			},
 			{
 				Name:      "edit",
@@ -176,48 +208,261 @@ SHORT HAND:
 						Name: "raw",
 					},
 				},
-				Action: editNote,
+				Action: withConfig(editNote),
+			},
+			{
+				Name:      "new",
+				ArgsUsage: "[title --] [content]",
+				Usage:     "creates a new note, same as running mark without a command",
+				Aliases:   []string{"n"},
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Usage:   "the title of the note",
+						Name:    "title",
+						Aliases: []string{"t"},
+					},
+					&cli.BoolFlag{
+						Usage: "reads the content of the note from stdin, any yaml front matter is merged into the header of the note",
+						Name:  "stdin",
+					},
+					&cli.StringFlag{
+						Usage: "creates the new note from a template stored in .mark/templates/<name>.md",
+						Name:  "template",
+					},
+				},
+				Action: withConfig(newNote),
+			},
+			{
+				Name:      "import-file",
+				ArgsUsage: "<file.md>...",
+				Usage:     "creates notes from markdown files, any yaml front matter is merged into the header of the note",
+				Action:    withConfig(importFiles),
+			},
+			{
+				Name:      "import",
+				ArgsUsage: "<path>",
+				Usage:     "imports notes from other note tools, i.e. an obsidian vault, a dir of markdown files or an evernote/joplin .enex export, or a bundle created by mark export",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Usage: "the kind of notes to import [obsidian | markdown-dir | enex]",
+						Name:  "from",
+					},
+					&cli.StringFlag{
+						Usage: "the format of a bundle created by mark export, read from <path> or stdin [jsonl | tar | zip]",
+						Name:  "format",
+					},
+					&cli.BoolFlag{
+						Usage: "overwrites existing notes that differ from the ones in the bundle",
+						Name:  "force",
+					},
+				},
+				Action: withConfig(func(c *cli.Context, cfg config.Config) error {
+					switch {
+					case c.IsSet("from") && c.IsSet("format"):
+						return errors.New("only one of --from and --format can be used")
+					case c.IsSet("format"):
+						return importBundle(c, cfg)
+					case c.IsSet("from"):
+						return importNotes(c, cfg)
+					default:
+						return errors.New("either --from or --format must be specified")
+					}
+				}),
+			},
+			{
+				Name:      "export",
+				ArgsUsage: "[file | :tag | free text search]",
+				Usage:     "exports notes to a single bundle on stdout, or to other formats using the sub commands",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Usage: "the format of the bundle [jsonl | tar | zip]",
+						Name:  "format",
+						Value: "jsonl",
+					},
+					&cli.StringFlag{
+						Usage:   "writes the bundle to a file instead of stdout",
+						Name:    "out",
+						Aliases: []string{"o"},
+					},
+				},
+				Action: withConfig(exportBundle),
+				Subcommands: []*cli.Command{
+					{
+						Name:      "html",
+						ArgsUsage: "<dir> [file | :tag | free text search]",
+						Usage:     "renders notes to a static html site, with pages for tags and search in the browser",
+						Action:    withConfig(exportHTML),
+					},
+				},
+			},
+			{
+				Name:      "append",
+				ArgsUsage: "[file | :tag | free text search] -- text, or content piped on stdin",
+				Usage:     "appends content to the end of an existing note",
+				Aliases:   []string{"a"},
+				Action:    withConfig(appendCmd),
+			},
+			{
+				Name:      "today",
+				ArgsUsage: "[-- text to append]",
+				Usage:     "opens the journal note of today, creating it if needed, or appends a timestamped bullet to it",
+				Action:    withConfig(today),
 			},
 			{
 				Name:   "reindex",
 				Usage:  "recalculates all free-text-search indexes",
-				Action: reindex,
+				Action: withConfig(reindex),
+			},
+			{
+				Name:  "migrate-layout",
+				Usage: "moves notes to the year/month dir of their filename, i.e. of the time of creation in UTC",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Usage: "only lists the notes that would be moved",
+						Name:  "dry-run",
+					},
+				},
+				Action: withConfig(migrateLayout),
+			},
+			{
+				Name:   "migrate-filenames",
+				Usage:  "renames notes to the filename scheme of the config, e.g. 'filenames: portable' for filenames without colons",
+				Action: withConfig(migrateFilenames),
+			},
+			{
+				Name:  "lint",
+				Usage: "reports broken [[links]], orphaned notes (no title, tags or inbound links) and duplicated aliases",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Usage: "outputs the report as json",
+						Name:  "json",
+					},
+				},
+				Action: withConfig(lint),
+			},
+			{
+				Name:      "attach",
+				ArgsUsage: "[file | :tag | free text search] file...",
+				Usage:     "attaches files to a note, storing them by content in the assets dir and linking them from the note",
+				Action:    withConfig(attach),
+			},
+			{
+				Name:  "fsck",
+				Usage: "reports assets not attached to any note and attachments whose asset is missing",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Usage: "deletes the assets not attached to any note",
+						Name:  "prune",
+					},
+				},
+				Action: withConfig(fsck),
+			},
+			{
+				Name:  "graph",
+				Usage: "outputs a graph of notes, tags and the [[links]] between them, e.g. `mark graph | dot -Tsvg > notes.svg`",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Usage: "the format of the graph [dot | json | graphml]",
+						Name:  "format",
+						Value: "dot",
+					},
+				},
+				Action: withConfig(graph),
 			},
 			{
 				Name:  "sync",
-				Usage: "equivalent to 'git add . && git commit -m \"sync\" && git pull && git push",
-				Action: func(c *cli.Context) error {
-
-					exe := func(args []string) error {
-						cmd := exec.Command("/proc/self/exe", args...)
-						cmd.Stdin = os.Stdin
-						cmd.Stdout = os.Stdout
-						cmd.Stderr = os.Stderr
-						return cmd.Run()
-					}
-
-					err := exe([]string{"git", "add", "."})
-					if err != nil {
-						fmt.Println("mark: are you sure you have git installed and the repo initialized? see `mark sync`", err)
-						return nil
-					}
-					exe([]string{"git", "commit", "-m", "sync commit"})
-					exe([]string{"git", "pull"})
-					exe([]string{"git", "push"})
-					return nil
+				Usage: "commits the changes of the notes, with a message summarizing them unless set in the config, and pulls and pushes them using git, the remote and branch can be set in the config",
+				Action: withConfig(func(c *cli.Context, cfg config.Config) error {
+					return syncCmd(cfg)
+				}),
+			},
+			{
+				Name:   "autopush",
+				Usage:  "pushes autocommits, retrying with backoff, started in the background when sync.autopush is set",
+				Hidden: true,
+				Action: withConfig(autopush),
+			},
+			{
+				Name:      "history",
+				ArgsUsage: "<note>",
+				Usage:     "lists the commits that changed a note, i.e. its history as synced by mark sync",
+				Action:    withConfig(history),
+			},
+			{
+				Name:      "diff",
+				ArgsUsage: "<note> [rev]",
+				Usage:     "outputs the changes of the header and a word diff of the body of a note since a git revision, defaults to HEAD",
+				Action:    withConfig(diffCmd),
+			},
+			{
+				Name:      "restore",
+				ArgsUsage: "<note> <rev>",
+				Usage:     "restores a note to how it was in a git revision, e.g. one listed by mark history",
+				Action:    withConfig(restore),
+			},
+			{
+				Name:  "notebooks",
+				Usage: "manages named notebooks, each with their own notes, indexes, config and git repo, used with mark -n <name>",
+				Subcommands: []*cli.Command{
+					{
+						Name:   "ls",
+						Usage:  "lists notebooks, the current one is marked with *",
+						Action: notebooksLs,
+					},
+					{
+						Name:      "add",
+						ArgsUsage: "<name> [path]",
+						Usage:     "adds a notebook stored in path, defaults to ~/.mark-notebooks/<name>",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Usage: "a git remote of the notebook, which is cloned unless path already exists",
+								Name:  "remote",
+							},
+						},
+						Action: notebooksAdd,
+					},
+					{
+						Name:      "rm",
+						ArgsUsage: "<name>",
+						Usage:     "removes a notebook from the list of notebooks, its notes are kept on disk",
+						Action:    notebooksRm,
+					},
 				},
 			},
+			{
+				Name:      "encrypt",
+				ArgsUsage: "[file | :tag | free text search]",
+				Usage:     "encrypts the body of a note using $MARK_PASSPHRASE or a prompted passphrase, the header is kept in the clear",
+				Action:    withConfig(encryptCmd),
+			},
+			{
+				Name:      "decrypt",
+				ArgsUsage: "[file | :tag | free text search]",
+				Usage:     "decrypts the body of an encrypted note, and stores it in the clear",
+				Action:    withConfig(decryptCmd),
+			},
+			{
+				Name:  "config",
+				Usage: "outputs the config in effect, read from ~/.config/mark/config.yaml, .mark/config.yaml and env vars",
+				Action: withConfig(func(c *cli.Context, cfg config.Config) error {
+					return yaml.NewEncoder(os.Stdout).Encode(cfg)
+				}),
+			},
 			{
 				Name:  "git",
 				Usage: "will performe git commands in dir the archive of notes is stored",
 				Action: func(c *cli.Context) error {
 
 					exe := func(args []string) error {
+						dir, err := fss.GetStoragePath()
+						if err != nil {
+							return err
+						}
 						cmd := exec.Command("git", args...)
 						cmd.Stdin = os.Stdin
 						cmd.Stdout = os.Stdout
 						cmd.Stderr = os.Stderr
-						cmd.Dir = fss.GetStoragePath()
+						cmd.Dir = dir
 						return cmd.Run()
 					}
 
@@ -228,84 +473,317 @@ SHORT HAND:
 		Flags: []cli.Flag{
 			&cli.StringFlag{
 				Name:  "format",
-				Usage: "defines the formatter of the md files used when outputting content [markdown | plain | raw | annotated]",
+				Usage: "defines the formatter of the md files used when outputting content [markdown | plain | raw | annotated | html | template]",
 
-				DefaultText: "markdown",
+				DefaultText: "markdown, or format of the config",
+			},
+			&cli.StringFlag{
+				Usage: "creates the new note from a template stored in .mark/templates/<name>.md",
+				Name:  "template",
+			},
+			&cli.StringFlag{
+				Usage: "the go text/template used when outputting notes with --format template, e.g. '{{.Title}} {{.CreatedAt}}'",
+				Name:  "output-template",
+			},
+			&cli.StringFlag{
+				Usage:       "the output of ls, ll and cat [text | json]",
+				Name:        "output",
+				DefaultText: "text",
+			},
+			&cli.StringFlag{
+				Usage: "the dir where notes are stored, overrides $MARK_HOME and ~/.mark",
+				Name:  "store",
+			},
+			&cli.StringFlag{
+				Usage:   "the name of the notebook to use, see mark notebooks",
+				Name:    "notebook",
+				Aliases: []string{"n"},
+			},
+			&cli.BoolFlag{
+				Usage: "searches notes in all notebooks with ls, ll, cat and pager",
+				Name:  "all-notebooks",
 			},
 			&cli.BoolFlag{
-				Usage: "when selecting a file to print or edit and MARK_PICKER is set, it will output the entire files to the picker instead of the filenames",
+				Usage: "when selecting a file to print or edit and a picker is configured, it will output the entire files to the picker instead of the filenames, i.e. picker_mode grep",
 				Name:  "grep",
 			},
 		},
-		Action: newNote,
+		Action: withConfig(newNote),
 	}
 
 	app.Before = func(context *cli.Context) error {
-		grep = context.Bool("grep") // ugly, ugly some refactoring is probably best to do
-		return nil
+		dir, err := storageOf(context)
+		if err != nil {
+			return err
+		}
+		fss.SetStoragePath(dir)
+		store = fss.NewFS(dir)
+		return checkOutput(context)
 	}
 	if err := app.Run(os.Args); err != nil {
 		log.Fatal(err)
 	}
 }
 
+// withConfig loads the config for an action, along with the global flags that overrides it
+func withConfig(action func(c *cli.Context, cfg config.Config) error) cli.ActionFunc {
+	return func(c *cli.Context) error {
+		dir, err := fss.GetStoragePath()
+		if err != nil {
+			return err
+		}
+		cfg, err := config.Load(dir)
+		if err != nil {
+			return err
+		}
+		if c.IsSet("format") {
+			cfg.Format = c.String("format")
+		}
+		if c.Bool("grep") {
+			cfg.PickerMode = "grep"
+		}
+		err = fss.SetFilenameScheme(cfg.Filenames)
+		if err != nil {
+			return err
+		}
+		return action(c, cfg)
+	}
+}
+
+func printerOf(c *cli.Context, cfg config.Config) (printer.Printer, error) {
+	return printer.Of(cfg.Format, printer.Options{
+		Template:   c.String("output-template"),
+		Width:      cfg.Printer.Width,
+		Style:      cfg.Printer.Style,
+		DateLayout: cfg.Printer.DateLayout,
+		Terms:      termsOf(c.Args().First()),
+		Snippets:   c.IsSet("context"),
+		Context:    c.Int("context"),
+		Color:      term.IsTerminal(int(os.Stdout.Fd())),
+		AssetPath:  assetPathOf,
+	})
+}
+
+// datePrefix matches queries for filenames, i.e. starting with the year
+var datePrefix = regexp.MustCompile("^([0-9]{3,4})")
+
+// termsOf returns what to highlight for a query given to ls, i.e. nothing for filenames and the #tag for tags
+func termsOf(query string) []string {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if len(query) == 0 || query == "-" || datePrefix.MatchString(query) {
+		return nil
+	}
+	if query[0] == ':' || query[0] == '#' {
+		return []string{"#" + query[1:]}
+	}
+	return []string{query}
+}
+
 func clils(c *cli.Context, verbose bool) error {
 	prefix := c.Args().First()
 
-	files, err := ls(prefix)
+	files, found, err := search(c, prefix)
 	if err != nil {
 		return err
 	}
+	store = found
+	if jsonOutput(c) {
+		return writeNotesJSON(os.Stdout, slicez.Sort(files), false)
+	}
 	slicez.Each(slicez.Sort(files), func(f string) {
+		name := path.Base(f)
 		if verbose {
-			name, _ := ll(f)
-			fmt.Println(name)
-			return
+			name, _ = ll(f)
+		}
+		if c.Bool("all-notebooks") {
+			name = fmt.Sprintf("%-12s %s", notebookOf(f), name)
 		}
-		fmt.Println(filepath.Base(f))
+		fmt.Println(name)
 	})
 	return err
 }
 
-func doEdit(file string) error {
-	data, err := ioutil.ReadFile(file)
+func doEdit(name string, cfg config.Config) error {
+	meta, content, err := readNote(name)
 	if err != nil {
 		return err
 	}
-	meta, content, err := mark.UnmarshalNote(data)
+
+	content, err = editInTemp(content, cfg)
 	if err != nil {
 		return err
 	}
 
-	f, err := os.CreateTemp("", "mark.*.md")
+	meta.UpdatedAt = time.Now()
+	meta.Tags = ts.GetTagsFromNote(content)
+	data, err := marshalNote(meta, content)
 	if err != nil {
 		return err
 	}
-	defer os.Remove(f.Name())
-	_, err = f.Write(content)
+	err = store.Put(name, data)
 	if err != nil {
 		return err
 	}
-	err = f.Close()
-	err = editFile(f.Name())
+	autoCommit(name, "edit", cfg)
+	return nil
+}
+
+// doEditRaw edits a note as is, including its yaml header
+func doEditRaw(name string, cfg config.Config) error {
+	data, err := store.Get(name)
+	if err != nil {
+		return err
+	}
+	data, err = editInTemp(data, cfg)
+	if err != nil {
+		return err
+	}
+	err = store.Put(name, data)
 	if err != nil {
 		return err
 	}
-	content, err = ioutil.ReadFile(f.Name())
+	autoCommit(name, "edit", cfg)
+	return nil
+}
+
+// editInTemp lets the user edit data in $EDITOR using a temporary file
+func editInTemp(data []byte, cfg config.Config) ([]byte, error) {
+	f, err := os.CreateTemp("", "mark.*.md")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(data)
+	if err != nil {
+		return nil, err
+	}
+	err = f.Close()
+	if err != nil {
+		return nil, err
+	}
+	err = editFile(f.Name(), cfg)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(f.Name())
+}
+
+// appendNote adds text on a new line at the end of a note, and updates tags and the index accordingly
+func appendNote(name string, text []byte, cfg config.Config) error {
+	meta, content, err := readNote(name)
 	if err != nil {
 		return err
 	}
+	if len(content) > 0 {
+		content = append(content, '\n')
+	}
+	content = append(content, bytes.TrimRight(text, "\n")...)
 
 	meta.UpdatedAt = time.Now()
 	meta.Tags = ts.GetTagsFromNote(content)
-	data, err = mark.MarshalNote(meta, content)
+	data, err := marshalNote(meta, content)
 	if err != nil {
 		return err
 	}
-	return ioutil.WriteFile(file, data, 0644)
+	err = store.Put(name, data)
+	if err != nil {
+		return err
+	}
+	err = updateIndex(name, cfg)
+	if err != nil {
+		return err
+	}
+	autoCommit(name, "append", cfg)
+	return nil
 }
 
-func editNote(c *cli.Context) error {
+func appendCmd(c *cli.Context, cfg config.Config) error {
+	query, text, _ := slicez.Cut(c.Args().Slice(), "--")
+
+	var addition []byte
+	if len(text) > 0 {
+		addition = []byte(strings.Join(text, " "))
+	} else if stdinIsPiped() {
+		var err error
+		addition, err = ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+	}
+	if len(bytes.TrimSpace(addition)) == 0 {
+		return errors.New("nothing to append, provide text after -- or pipe it on stdin")
+	}
+
+	file, err := findNote(strings.Join(query, " "), cfg)
+	if err != nil {
+		return err
+	}
+	return appendNote(file, append([]byte("\n"), addition...), cfg)
+}
+
+func stdinIsPiped() bool {
+	stat, err := os.Stdin.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice == 0
+}
+
+// findNote resolves a filename, or a query that is narrowed down using the picker, to a single note
+func findNote(query string, cfg config.Config) (string, error) {
+	if name, ok := existingName(query); ok {
+		return name, nil
+	}
+
+	if len(query) == 0 {
+		return "", errors.New("a note must be specified")
+	}
+	files, err := ls(query)
+	if err != nil {
+		return "", err
+	}
+	if len(files) == 0 {
+		return "", fmt.Errorf("no entries found for %s", query)
+	}
+	if len(files) > 1 && stdinIsPiped() {
+		return "", fmt.Errorf("%d notes found for %s, can't pick one while reading stdin", len(files), query)
+	}
+	return pickFile(files, cfg)
+}
+
+// prefixInSchemes returns a prefix of a filename in each filename scheme, i.e. with the time written using colons
+// and dashes
+func prefixInSchemes(prefix string) []string {
+	date, rest, ok := strings.Cut(prefix, "_")
+	if !ok {
+		return []string{prefix}
+	}
+	clock := rest
+	if len(clock) > len("15:04:05") {
+		clock = clock[:len("15:04:05")]
+	}
+	rest = rest[len(clock):]
+	return slicez.Uniq([]string{
+		prefix,
+		date + "_" + strings.ReplaceAll(clock, "-", ":") + rest,
+		date + "_" + strings.ReplaceAll(clock, ":", "-") + rest,
+	})
+}
+
+// existingName returns the name of the note of a filename, with or without .md and in any filename scheme
+func existingName(filename string) (string, bool) {
+	for _, f := range []string{filename, filename + ".md"} {
+		names, err := fss.GetFilenameToNames(f)
+		if err != nil {
+			continue
+		}
+		for _, name := range names {
+			if _, err := store.Get(name); err == nil {
+				return name, true
+			}
+		}
+	}
+	return "", false
+}
+
+func editNote(c *cli.Context, cfg config.Config) error {
 	prefix := c.Args().First()
 
 	files, err := ls(prefix)
@@ -317,43 +795,48 @@ func editNote(c *cli.Context) error {
 		return nil
 	}
 
-	file, err := pickFile(files)
+	file, err := pickFile(files, cfg)
 	if err != nil {
 		return err
 	}
 
-	defer updateIndex(file)
+	defer updateIndex(file, cfg)
 	if c.Bool("raw") {
-		return editFile(file)
+		return doEditRaw(file, cfg)
 	}
-	return doEdit(file)
+	return doEdit(file, cfg)
 }
 
-func ll(f string) (string, error) {
+// readHeader reads only the yaml header of a note
+func readHeader(name string) (mark.Header, error) {
+	var meta mark.Header
 
-	file, err := os.Open(f)
+	data, err := store.Get(name)
 	if err != nil {
-		return "", err
+		return meta, err
 	}
-	defer file.Close()
 
-	buf := bufio.NewReader(file)
+	buf := bufio.NewReader(bytes.NewReader(data))
 	header := ""
 	_, _, err = buf.ReadLine() // discard
 	if err != nil {
-		return "", err
+		return meta, err
 	}
 	line := ""
 	for line != "---" {
 		b, _, err := buf.ReadLine() // discard
 		if err != nil {
-			return "", err
+			return meta, err
 		}
 		line = string(b)
 		header += fmt.Sprintln(line)
 	}
-	var meta mark.Header
 	err = yaml.Unmarshal([]byte(header), &meta)
+	return meta, err
+}
+
+func ll(f string) (string, error) {
+	meta, err := readHeader(f)
 	if err != nil {
 		return "", err
 	}
@@ -367,16 +850,16 @@ func ll(f string) (string, error) {
 	if len(tags) > 0 {
 		parts = append(parts, fmt.Sprint(tags))
 	}
-	name := filepath.Base(f)
-	return fmt.Sprintf("%s %s %s", name, strings.Repeat(" ", 33-len(name)), strings.Join(parts, " ")), nil
+	name := path.Base(f)
+	return fmt.Sprintf("%-36s %s", name, strings.Join(parts, " ")), nil
 }
 
-func pickFile(files []string) (string, error) {
+func pickFile(files []string, cfg config.Config) (string, error) {
 
 	if len(files) == 1 {
 		return files[0], nil
 	}
-	picker := os.Getenv("MARK_PICKER")
+	picker := cfg.Picker
 	if len(picker) > 0 {
 		parts, err := shellwords.Parse(picker)
 		if err != nil {
@@ -388,15 +871,7 @@ func pickFile(files []string) (string, error) {
 		cmd.Stdin = reader
 
 		go func() {
-			mode := os.Getenv("MARK_PICKER_MODE")
-			if grep {
-				mode = "grep"
-			}
-			if mode != "grep" {
-				mode = "file"
-			}
-
-			switch mode {
+			switch cfg.PickerMode {
 			case "grep":
 				_, _ = io.Copy(writer, cat(files, printer.AnnotatedPrinter))
 			default:
@@ -451,9 +926,9 @@ func pickFile(files []string) (string, error) {
 	return slicez.Nth(files, i-1), nil
 }
 
-func newNote(c *cli.Context) error {
+func newNote(c *cli.Context, cfg config.Config) error {
 	meta := mark.Header{
-		Title:     c.String("t"),
+		Title:     c.String("title"),
 		Tags:      nil,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
@@ -470,21 +945,83 @@ func newNote(c *cli.Context) error {
 
 	contentBytes := []byte(strings.Join(content, " "))
 
+	if c.Bool("stdin") {
+		data, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		var input []byte
+		meta, input, err = withFrontMatter(meta, data)
+		if err != nil {
+			return err
+		}
+		if len(contentBytes) > 0 {
+			contentBytes = append(contentBytes, []byte("\n\n")...)
+		}
+		contentBytes = append(contentBytes, input...)
+	}
+
+	if name := c.String("template"); len(name) > 0 {
+		var err error
+		meta, contentBytes, err = applyTemplate(name, meta, contentBytes, c.Bool("stdin"))
+		if err != nil {
+			return err
+		}
+	}
+
 	meta.Tags = ts.GetTagsFromNote(contentBytes)
-	note, err := mark.MarshalNote(meta, contentBytes)
+	meta.Encrypted = cfg.Encrypt
+	name, _, err := saveNewNote(meta, contentBytes)
 	if err != nil {
 		return err
 	}
-	filename, err := fss.SaveNote(meta, note)
+
+	if c.Args().Len() == 0 && !c.Bool("stdin") {
+		doEdit(name, cfg)
+	}
+
+	err = updateIndex(name, cfg)
 	if err != nil {
 		return err
 	}
+	// a no-op if the note was committed when edited
+	autoCommit(name, "new", cfg)
+	return nil
+}
+
+// applyTemplate creates the content of a note from a template, prompting on stdin unless it is already read, i.e.
+// consumed by --stdin, in which case templates with prompts are refused
+func applyTemplate(name string, meta mark.Header, content []byte, stdinRead bool) (mark.Header, []byte, error) {
+	tmplMeta, tmplContent, err := tmpl.Load(name)
+	if err != nil {
+		return meta, nil, err
+	}
 
-	if c.Args().Len() == 0 {
-		doEdit(filename)
+	stdin := bufio.NewReader(os.Stdin)
+	prompt := func(label string) (string, error) {
+		if stdinRead {
+			return "", fmt.Errorf("can not prompt for %s, the content of the note is read from stdin", label)
+		}
+		fmt.Fprintf(os.Stderr, "%s: ", label)
+		line, err := stdin.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", err
+		}
+		return strings.TrimSpace(line), nil
+	}
+	tmplMeta, tmplContent, err = tmpl.Expand(tmplMeta, tmplContent, meta.CreatedAt, prompt)
+	if err != nil {
+		return meta, nil, fmt.Errorf("could not expand template %s: %w", name, err)
 	}
 
-	return updateIndex(filename)
+	if len(meta.Title) == 0 {
+		meta.Title = tmplMeta.Title
+	}
+	tmplContent = bytes.TrimSpace(tmplContent)
+	if len(content) > 0 {
+		tmplContent = append(append(tmplContent, []byte("\n\n")...), content...)
+	}
+	return meta, ts.EnsureTags(tmplContent, tmplMeta.Tags), nil
 }
 
 func ls(prefix string) ([]string, error) {
@@ -511,12 +1048,22 @@ func ls(prefix string) ([]string, error) {
 			month = prefix[5:7]
 		}
 
-		glob := fmt.Sprintf("%s/%s/%s/%s*", fss.GetLibPath(), year, month, prefix)
-		files, err := filepath.Glob(glob)
+		// the prefix may be of a filename in either filename scheme, e.g. 2022-08-12_14:04 or 2022-08-12_14-04
+		var globs []string
+		for _, p := range prefixInSchemes(prefix) {
+			globs = append(globs, fmt.Sprintf("%s/%s/%s*", year, month, p))
+		}
+		names, err := store.List()
 		if err != nil {
 			return nil, err
 		}
-		if len(files) > 0 {
+		files := slicez.Filter(names, func(name string) bool {
+			return slicez.SomeFunc(globs, func(glob string) bool {
+				match, _ := path.Match(glob, name)
+				return match
+			})
+		})
+		if len(files) > 0 || len(prefix) == 0 {
 			return slicez.Reverse(slicez.Sort(files)), nil
 		}
 	}
@@ -526,33 +1073,31 @@ func ls(prefix string) ([]string, error) {
 		prefix = prefix[1:]
 	}
 
-	var data []byte
-	var err error
-
-	index := mark.NewIndex()
-	data, err = ioutil.ReadFile(filepath.Join(fss.GetStoragePath(), "index.json"))
+	index, err := readIndex()
 	if err != nil {
 		return nil, err
 	}
-	err = json.Unmarshal(data, &index)
+	names, err := store.List()
 	if err != nil {
 		return nil, err
 	}
-
-	tagFiles := slicez.Map(index.TagsToId[prefix], func(a int) string {
-		f, err := fss.GetFilenameToPath(index.IdToName[a])
-		if err != nil {
-			return ""
-		}
-		if _, err := os.Stat(f); errors.Is(err, os.ErrNotExist) {
+	exists := map[string]bool{}
+	for _, name := range names {
+		exists[name] = true
+	}
+	nameOf := func(id int) string {
+		name, err := fss.GetFilenameToName(index.IdToName[id])
+		if err != nil || !exists[name] {
 			return ""
 		}
-		return f
-	})
+		return name
+	}
+
+	tagFiles := slicez.Map(index.TagsToId[prefix], nameOf)
 
 	var tsFiles []string
 	if !specificTag {
-		data, err = ioutil.ReadFile(filepath.Join(fss.GetStoragePath(), "index.tsar"))
+		data, err := store.ReadIndex("index.tsar")
 		if err != nil {
 			return nil, err
 		}
@@ -567,14 +1112,7 @@ func ls(prefix string) ([]string, error) {
 
 		tsFiles = slicez.Flatten(slicez.Map(entries, func(entry *tsar.Entry) []string {
 			return slicez.Map(entry.Pointers, func(a uint32) string {
-				f, err := fss.GetFilenameToPath(index.IdToName[int(a)])
-				if err != nil {
-					return ""
-				}
-				if _, err := os.Stat(f); errors.Is(err, os.ErrNotExist) {
-					return ""
-				}
-				return f
+				return nameOf(int(a))
 			})
 		}))
 	}
@@ -584,30 +1122,60 @@ func ls(prefix string) ([]string, error) {
 	})), nil
 }
 
-func page(files []string, printer printer.Printer) error {
+func readIndex() (mark.Index, error) {
+	index := mark.NewIndex()
+	data, err := store.ReadIndex("index.json")
+	if err != nil {
+		return index, err
+	}
+	err = json.Unmarshal(data, &index)
+	return index, err
+}
 
-	pager := os.Getenv("PAGER")
-	if len(pager) == 0 {
-		pager = "less -r"
+// readNote reads a note, decrypting its content if it is encrypted
+func readNote(name string) (mark.Header, []byte, error) {
+	data, err := store.Get(name)
+	if err != nil {
+		return mark.Header{}, nil, err
+	}
+	header, content, err := mark.UnmarshalNote(data)
+	if err != nil {
+		return header, nil, err
+	}
+	content, err = decryptNote(header, content)
+	return header, content, err
+}
+
+// readIndexable reads a note to be indexed, the content of encrypted notes is left out to not end up in the index
+func readIndexable(name string) (mark.Header, []byte, error) {
+	data, err := store.Get(name)
+	if err != nil {
+		return mark.Header{}, nil, err
+	}
+	header, content, err := mark.UnmarshalNote(data)
+	if header.Encrypted {
+		content = nil
 	}
+	return header, content, err
+}
+
+func page(files []string, printer printer.Printer, cfg config.Config) error {
 
-	pa := strings.Split(pager, " ")
+	pa := strings.Split(cfg.Pager, " ")
 	cmd := exec.Command(pa[0], pa[1:]...)
-	b, _ := ioutil.ReadAll(cat(files, printer))
+	b, err := ioutil.ReadAll(cat(files, printer))
+	if err != nil {
+		return err
+	}
 	cmd.Stdin = bytes.NewReader(b) // for some reason having a reader with all the content from the beginning works with less, but not a regular reader.
 	cmd.Stdout = os.Stdout
 
 	return cmd.Run()
 }
 
-func editFile(file string) error {
+func editFile(file string, cfg config.Config) error {
 
-	editor := os.Getenv("EDITOR")
-	if len(editor) == 0 {
-		editor = "nano"
-	}
-
-	pa := strings.Split(editor, " ")
+	pa := strings.Split(cfg.Editor, " ")
 	pa = append(pa, file)
 	cmd := exec.Command(pa[0], pa[1:]...)
 	cmd.Stdin = os.Stdin
@@ -624,51 +1192,45 @@ func editFile(file string) error {
 func cat(files []string, printer printer.Printer) io.Reader {
 	r, w := io.Pipe()
 	go func() {
-		defer w.Close()
 		for _, file := range files {
-			data, err := ioutil.ReadFile(file)
+			header, content, err := readNote(file)
 			if err != nil {
-				panic(err)
+				_ = w.CloseWithError(fmt.Errorf("could not read note %s: %w", file, err))
+				return
 			}
 
-			header, content, err := mark.UnmarshalNote(data)
+			data, err := printer(header, content, file)
 			if err != nil {
-				panic(err)
+				_ = w.CloseWithError(fmt.Errorf("could not print note %s: %w", file, err))
+				return
 			}
-
-			data = printer(header, content, file)
 			_, err = io.Copy(w, bytes.NewReader(data))
-
 			if err != nil {
-				panic(err)
+				_ = w.CloseWithError(err)
+				return
 			}
 		}
+		_ = w.Close()
 	}()
 
 	return r
 }
 
-func updateIndex(file string) error {
+func updateIndex(file string, cfg config.Config) error {
 
-	data, err := ioutil.ReadFile(file)
-	if err != nil {
-		return err
-	}
-	header, content, err := mark.UnmarshalNote(data)
+	header, content, err := readIndexable(file)
 	if err != nil {
 		return err
 	}
 
 	// Updating json mapping index
 
-	jsonIndexName := filepath.Join(fss.GetStoragePath(), "index.json")
-	var jsonindexdata = []byte("{}")
-
-	if _, err := os.Stat(jsonIndexName); err == nil {
-		jsonindexdata, err = ioutil.ReadFile(jsonIndexName)
-		if err != nil {
-			return err
-		}
+	jsonindexdata, err := store.ReadIndex("index.json")
+	if errors.Is(err, fs.ErrNotExist) {
+		jsonindexdata, err = []byte("{}"), nil
+	}
+	if err != nil {
+		return err
 	}
 
 	var jsonindex = mark.NewIndex()
@@ -677,7 +1239,7 @@ func updateIndex(file string) error {
 		return err
 	}
 
-	name := filepath.Base(file)
+	name := path.Base(file)
 	maxId := slicez.Max[int](mapz.Keys(jsonindex.IdToName)...)
 	nameToId := mapz.Remap(jsonindex.IdToName, func(k int, v string) (string, int) {
 		return v, k
@@ -704,20 +1266,18 @@ func updateIndex(file string) error {
 	if err != nil {
 		return err
 	}
-	err = ioutil.WriteFile(jsonIndexName, jsonindexdata, 0644)
+	err = store.WriteIndex("index.json", jsonindexdata)
 	if err != nil {
 		return err
 	}
 
 	// Updating tsindexdata could be slow eventually?
-	tsarIndexName := filepath.Join(fss.GetStoragePath(), "index.tsar")
 	var tsindex = &tsar.Index{}
-	var tsindexdata []byte
-	if _, err := os.Stat(tsarIndexName); err == nil {
-		tsindexdata, err = ioutil.ReadFile(tsarIndexName)
-		if err != nil {
-			return err
-		}
+	tsindexdata, err := store.ReadIndex("index.tsar")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err == nil {
 		tsindex, err = tsar.UnmarshalIndex(tsindexdata)
 		if err != nil {
 			return err
@@ -725,7 +1285,7 @@ func updateIndex(file string) error {
 	}
 
 	wordlist := tsindex.EntryList()
-	for _, word := range ts.TokenizeText(string(content)) {
+	for _, word := range tokenizerOf(cfg).Tokenize(string(content)) {
 		err = wordlist.Append(word, uint32(id))
 		if err != nil {
 			return err
@@ -733,7 +1293,7 @@ func updateIndex(file string) error {
 	}
 	tsindex = wordlist.ToIndex()
 	tsindexdata = tsar.MarshalIndex(tsindex)
-	err = ioutil.WriteFile(tsarIndexName, tsindexdata, 0644)
+	err = store.WriteIndex("index.tsar", tsindexdata)
 	if err != nil {
 		return err
 	}
@@ -741,7 +1301,15 @@ func updateIndex(file string) error {
 	return nil
 }
 
-func reindex(c *cli.Context) error {
+func reindex(c *cli.Context, cfg config.Config) error {
+	return reindexAll(cfg)
+}
+
+func tokenizerOf(cfg config.Config) ts.Tokenizer {
+	return ts.Tokenizer{MinLength: cfg.Tokenizer.MinLength, StopWords: cfg.Tokenizer.StopWords}
+}
+
+func reindexAll(cfg config.Config) error {
 
 	index := mark.NewIndex()
 	wordlist := tsar.NewEntryList()
@@ -752,12 +1320,8 @@ func reindex(c *cli.Context) error {
 	}
 
 	for id, f := range slicez.Sort(files) {
-		index.IdToName[id] = filepath.Base(f)
-		data, err := ioutil.ReadFile(f)
-		if err != nil {
-			return err
-		}
-		header, content, err := mark.UnmarshalNote(data)
+		index.IdToName[id] = path.Base(f)
+		header, content, err := readIndexable(f)
 		if err != nil {
 			return err
 		}
@@ -766,7 +1330,7 @@ func reindex(c *cli.Context) error {
 			tags := index.TagsToId[tag]
 			index.TagsToId[tag] = append(tags, id)
 		}
-		for _, word := range ts.TokenizeText(string(content)) {
+		for _, word := range tokenizerOf(cfg).Tokenize(string(content)) {
 			err = wordlist.Append(word, uint32(id))
 			if err != nil {
 				return err
@@ -779,13 +1343,13 @@ func reindex(c *cli.Context) error {
 		return err
 	}
 
-	err = ioutil.WriteFile(filepath.Join(fss.GetStoragePath(), "index.json"), jsonindex, 0644)
+	err = store.WriteIndex("index.json", jsonindex)
 	if err != nil {
 		return err
 	}
 
 	tsindex := tsar.MarshalIndex(wordlist.ToIndex())
-	err = ioutil.WriteFile(filepath.Join(fss.GetStoragePath(), "index.tsar"), tsindex, 0644)
+	err = store.WriteIndex("index.tsar", tsindex)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,518 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/crholm/mark"
+	"github.com/crholm/mark/internal/config"
+	"github.com/crholm/mark/internal/fss"
+	"github.com/crholm/mark/internal/git"
+	"github.com/crholm/mark/internal/ts"
+	"github.com/urfave/cli/v2"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// contextOf returns a context of a command, having the flags used by the tests, parsed from args
+func contextOf(t *testing.T, args ...string) *cli.Context {
+	t.Helper()
+	set := flag.NewFlagSet("mark", flag.ContinueOnError)
+	set.String("store", "", "")
+	set.String("notebook", "", "")
+	set.Bool("all-notebooks", false, "")
+	set.String("format", "", "")
+	set.String("out", "", "")
+	set.Bool("force", false, "")
+	set.String("output", "", "")
+	set.Bool("grep", false, "")
+	set.Bool("dry-run", false, "")
+	err := set.Parse(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cli.NewContext(nil, set, nil)
+}
+
+// stdoutOf returns what is printed to stdout by f
+func stdoutOf(t *testing.T, f func() error) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	err = f()
+	os.Stdout = stdout
+	_ = w.Close()
+	out, _ := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func saveNote(t *testing.T, created time.Time, title string, content string) string {
+	t.Helper()
+	meta := mark.Header{
+		Title:     title,
+		Tags:      ts.GetTagsFromNote([]byte(content)),
+		CreatedAt: created,
+		UpdatedAt: created,
+	}
+	name, _, err := fss.SaveNote(store, meta, []byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = updateIndex(name, config.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestLsAndAppend(t *testing.T) {
+	store = fss.NewMemory()
+	cfg := config.Default()
+
+	groceries := saveNote(t, time.Date(2022, 8, 12, 14, 4, 49, 0, time.UTC), "Groceries", "Buy milk and eggs #home")
+	meeting := saveNote(t, time.Date(2022, 9, 1, 9, 0, 0, 0, time.UTC), "Meeting", "About kubernetes #work")
+
+	for query, expected := range map[string][]string{
+		"":        {meeting, groceries},
+		"2022-08": {groceries},
+		":home":   {groceries},
+		"#work":   {meeting},
+		"kube":    {meeting},
+		"MILK":    {groceries},
+		"nothing": nil,
+	} {
+		files, err := ls(query)
+		if err != nil {
+			t.Fatalf("ls %q: %v", query, err)
+		}
+		if !reflect.DeepEqual(files, expected) {
+			t.Fatalf("ls %q: expected %v, got %v", query, expected, files)
+		}
+	}
+
+	file, err := findNote("2022-08-12_14:04:49.000Z_Friday", cfg)
+	if err != nil || file != groceries {
+		t.Fatalf("expected to find %s by filename, got %s, %v", groceries, file, err)
+	}
+	err = appendNote(groceries, []byte("and coffee #errands"), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, content, err := readNote(groceries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(content), "\nand coffee #errands") {
+		t.Fatalf("expected the text to be appended, got %q", content)
+	}
+	if !reflect.DeepEqual(meta.Tags, []string{"home", "errands"}) {
+		t.Fatalf("expected tags to be updated, got %v", meta.Tags)
+	}
+	for _, query := range []string{"coffee", ":errands"} {
+		files, err := ls(query)
+		if err != nil || !reflect.DeepEqual(files, []string{groceries}) {
+			t.Fatalf("ls %q: expected the appended note to be indexed, got %v, %v", query, files, err)
+		}
+	}
+
+	err = store.Delete(meeting)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := ls("kube")
+	if err != nil || len(files) != 0 {
+		t.Fatalf("expected deleted notes to not be listed, got %v, %v", files, err)
+	}
+}
+
+func TestAttach(t *testing.T) {
+	store = fss.NewMemory()
+	cfg := config.Default()
+
+	note := saveNote(t, time.Date(2022, 8, 12, 14, 4, 49, 0, time.UTC), "Trip", "Photos from the trip")
+	file := filepath.Join(t.TempDir(), "Beach.PNG")
+	err := os.WriteFile(file, []byte("png"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err = attachFiles(note, []string{file}, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	asset := fss.AssetName(file, []byte("png"))
+	meta, content, err := readNote(note)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []mark.Attachment{{Name: "Beach.PNG", Asset: asset}}
+	if !reflect.DeepEqual(meta.Attachments, expected) {
+		t.Fatalf("expected attachments %v once, got %v", expected, meta.Attachments)
+	}
+	link := fmt.Sprintf("\n\n![Beach.PNG](../../../assets/%s)", asset)
+	if !strings.HasSuffix(string(content), link) {
+		t.Fatalf("expected a link to the asset to be appended, got %q", content)
+	}
+	data, err := store.GetAsset(asset)
+	if err != nil || string(data) != "png" {
+		t.Fatalf("expected the asset to be stored, got %q, %v", data, err)
+	}
+}
+
+// testGit skips the test if git is not installed, and commits as mark@example.com regardless of the git config
+func testGit(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	for env, value := range map[string]string{
+		"GIT_AUTHOR_NAME":     "mark",
+		"GIT_COMMITTER_NAME":  "mark",
+		"GIT_AUTHOR_EMAIL":    "mark@example.com",
+		"GIT_COMMITTER_EMAIL": "mark@example.com",
+		"GIT_CONFIG_GLOBAL":   os.DevNull,
+	} {
+		t.Setenv(env, value)
+	}
+}
+
+func TestSync(t *testing.T) {
+	testGit(t)
+	dir := t.TempDir()
+	for _, args := range [][]string{{"init", "--quiet", "--bare", "remote.git"}, {"clone", "--quiet", "remote.git", "work"}} {
+		_, err := git.New(dir).Run(args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := config.Default()
+	err := syncNotes(git.New(t.TempDir()), cfg)
+	if err == nil || !strings.Contains(err.Error(), "not in a git repo") {
+		t.Fatalf("expected an error syncing notes not in a repo, got %v", err)
+	}
+
+	work := git.New(filepath.Join(dir, "work"))
+	store = fss.NewFS(work.Dir)
+	name := saveNote(t, time.Date(2022, 8, 12, 14, 4, 49, 0, time.UTC), "Groceries", "Buy milk #home")
+	cfg.Sync.Remote = "origin"
+	err = syncNotes(work, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message, err := work.Run("log", "-1", "--format=%B")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := fmt.Sprintf("sync: 1 added\n\nadded    Groceries (%s)", path.Base(name))
+	if strings.TrimSpace(string(message)) != expected {
+		t.Fatalf("expected the commit message %q, got %q", expected, message)
+	}
+
+	_, err = git.New(dir).Run("clone", "--quiet", "remote.git", "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "other", "lib", filepath.FromSlash(name))); err != nil {
+		t.Fatalf("expected the note to be pushed, %v", err)
+	}
+
+	// a second sync, with nothing to commit, pulls and pushes
+	err = syncNotes(work, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := work.Run("ls-files", "--error-unmatch", "index.json"); err == nil {
+		t.Fatal("expected the indexes to not be synced")
+	}
+
+	// the note is edited in both clones, once in different lines and then in the same line
+	other := git.New(filepath.Join(dir, "other"))
+	edit := func(r git.Runner, title string, content string, tags []string, updated time.Duration) {
+		t.Helper()
+		store = fss.NewFS(r.Dir)
+		meta, _, err := readNote(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		meta.Title = title
+		meta.Alias = "shopping"
+		meta.Tags = tags
+		meta.UpdatedAt = meta.CreatedAt.Add(updated)
+		data, err := mark.MarshalNote(meta, []byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = store.Put(name, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = syncNotes(r, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	edit(other, "Groceries", "Buy milk #home\n\nand bread\n\nfor the weekend", []string{"home"}, time.Minute)
+	edit(work, "Groceries", "Buy milk #home\n\nand bread\n\nfor the weekend", []string{"home"}, time.Minute)
+	edit(other, "Groceries", "Buy milk #home\n\nand bread\n\nfor the weekend #weekend", []string{"home", "weekend"}, time.Hour)
+	edit(work, "Shopping", "Buy oat milk #home\n\nand bread\n\nfor the weekend", []string{"home", "shop"}, 2*time.Minute)
+	meta, content, err := readNote(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Title != "Shopping" || !reflect.DeepEqual(meta.Tags, []string{"home", "shop", "weekend"}) ||
+		!meta.UpdatedAt.Equal(meta.CreatedAt.Add(time.Hour)) || string(content) != "Buy oat milk #home\n\nand bread\n\nfor the weekend #weekend" {
+		t.Fatalf("expected the note to be merged, got %+v\n%s", meta, content)
+	}
+	files, err := ls("")
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected a single note in the rebuilt index, got %v, %v", files, err)
+	}
+
+	store = fss.NewFS(other.Dir)
+	err = syncNotes(other, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edit(other, "Shopping", "Buy oat milk #home\n\nand butter", []string{"home"}, 2*time.Hour)
+	edit(work, "Shopping", "Buy oat milk #home\n\nand cheese", []string{"home"}, 3*time.Hour)
+	names, err := store.List()
+	if err != nil || len(names) != 2 || names[0] != name {
+		t.Fatalf("expected the note and a conflict copy, got %v, %v", names, err)
+	}
+	meta, content, err = readNote(names[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Title != "Shopping (conflict copy)" || string(content) != "Buy oat milk #home\n\nand butter\n\n#conflict" {
+		t.Fatalf("expected the conflict copy to be their version, got %+v\n%s", meta, content)
+	}
+	if meta.Alias != "" || !reflect.DeepEqual(meta.Tags, []string{"home", "conflict"}) {
+		t.Fatalf("expected the conflict copy to be tagged #conflict and to not have the alias of the note, got %+v", meta)
+	}
+	files, err = ls(":conflict")
+	if err != nil || !reflect.DeepEqual(files, names[1:]) {
+		t.Fatalf("expected the conflict copy to be found by its tag, got %v, %v", files, err)
+	}
+	_, content, err = readNote(name)
+	if err != nil || string(content) != "Buy oat milk #home\n\nand cheese" {
+		t.Fatalf("expected the note to keep our version, got %q, %v", content, err)
+	}
+}
+
+func TestAutoCommit(t *testing.T) {
+	testGit(t)
+	r := git.New(t.TempDir())
+	_, err := r.Run("init", "--quiet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fss.SetStoragePath(r.Dir)
+	store = fss.NewFS(r.Dir)
+	cfg := config.Default()
+	cfg.Sync.Autocommit = true
+
+	groceries := saveNote(t, time.Date(2022, 8, 12, 14, 4, 49, 0, time.UTC), "Groceries", "Buy milk #home")
+	err = commitChange(groceries, "new", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := r.Head()
+	if err != nil || head.Subject != "new: Groceries" {
+		t.Fatalf("expected a commit of the new note, got %+v, %v", head, err)
+	}
+
+	// changes in quick succession are amended to the same commit
+	err = appendNote(groceries, []byte("and bread"), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = appendNote(groceries, []byte("and eggs"), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err = r.Head()
+	if err != nil || head.Subject != "autocommit: 3 changes" || head.Body != "new: Groceries\nappend: Groceries\nappend: Groceries" {
+		t.Fatalf("expected the changes to be batched, got %+v, %v", head, err)
+	}
+	commits, err := r.Run("rev-list", "--count", "HEAD")
+	if err != nil || strings.TrimSpace(string(commits)) != "1" {
+		t.Fatalf("expected a single commit, got %s, %v", commits, err)
+	}
+	if _, err := r.Run("ls-files", "--error-unmatch", "index.json"); err == nil {
+		t.Fatal("expected the indexes to not be committed")
+	}
+
+	err = store.Delete(groceries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = commitChange(groceries, "rm", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err = r.Head()
+	if err != nil || head.Subject != "autocommit: 4 changes" || head.Body != "new: Groceries\nappend: Groceries\nappend: Groceries\nrm: Groceries" {
+		t.Fatalf("expected the removal to be batched, got %+v, %v", head, err)
+	}
+
+	// attached assets are committed along with the note, encrypting and decrypting is committed as well
+	t.Setenv("MARK_PASSPHRASE", "correct horse battery staple")
+	trip := saveNote(t, time.Date(2022, 8, 20, 10, 0, 0, 0, time.UTC), "Trip", "Photos from the trip")
+	err = commitChange(trip, "new", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "Beach.PNG")
+	err = os.WriteFile(file, []byte("png"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = attach(contextOf(t, "2022-08-20", file), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Run("ls-files", "--error-unmatch", path.Join("assets", fss.AssetName(file, []byte("png")))); err != nil {
+		t.Fatalf("expected the attached asset to be committed, got %v", err)
+	}
+	for _, cmd := range []func(*cli.Context, config.Config) error{encryptCmd, decryptCmd} {
+		err = cmd(contextOf(t, "2022-08-20"), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	head, err = r.Head()
+	if err != nil || !strings.HasSuffix(head.Body, "new: Trip\nattach: Trip\nencrypt: Trip\ndecrypt: Trip") {
+		t.Fatalf("expected attaching, encrypting and decrypting to be batched, got %+v, %v", head, err)
+	}
+	changes, err := r.Status()
+	if err != nil || len(changes) != 0 {
+		t.Fatalf("expected every change to be committed, got %v, %v", changes, err)
+	}
+
+	// a change that can not be committed is still saved
+	dir := t.TempDir()
+	fss.SetStoragePath(dir)
+	store = fss.NewFS(dir)
+	groceries = saveNote(t, time.Date(2022, 8, 12, 14, 4, 49, 0, time.UTC), "Groceries", "Buy milk #home")
+	err = commitChange(groceries, "new", cfg)
+	if err == nil || !strings.Contains(err.Error(), "not in a git repo") {
+		t.Fatalf("expected an error committing notes not in a git repo, got %v", err)
+	}
+	err = appendNote(groceries, []byte("and bread"), cfg)
+	if err != nil {
+		t.Fatalf("expected the note to be appended to without a commit of it, got %v", err)
+	}
+}
+
+func TestWithConfig(t *testing.T) {
+	dir := t.TempDir()
+	fss.SetStoragePath(dir)
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("MARK_PICKER_MODE", "file")
+	err := os.WriteFile(config.StoragePath(dir), []byte("format: plain\npicker_mode: grep\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		args       []string
+		format     string
+		pickerMode string
+	}{
+		{nil, "plain", "file"},
+		{[]string{"--format", "raw", "--grep"}, "raw", "grep"},
+	} {
+		var cfg config.Config
+		err = withConfig(func(c *cli.Context, loaded config.Config) error {
+			cfg = loaded
+			return nil
+		})(contextOf(t, c.args...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Format != c.format || cfg.PickerMode != c.pickerMode {
+			t.Fatalf("expected the flags %v to give format %s and picker mode %s, got %s and %s",
+				c.args, c.format, c.pickerMode, cfg.Format, cfg.PickerMode)
+		}
+	}
+}
+
+func TestApplyTemplate(t *testing.T) {
+	dir := t.TempDir()
+	fss.SetStoragePath(dir)
+	err := os.MkdirAll(filepath.Join(dir, "templates"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string]string{
+		"meeting": "---\ntitle: Meeting {{date}}\ntags: [work]\n---\n# Agenda",
+		"client":  "---\ntitle: Meeting with {{prompt \"Customer\"}}\n---\n",
+	} {
+		err = os.WriteFile(filepath.Join(dir, "templates", name+".md"), []byte(data), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	meta := mark.Header{CreatedAt: time.Date(2022, 8, 12, 14, 4, 49, 0, time.UTC)}
+
+	header, content, err := applyTemplate("meeting", meta, []byte("from stdin"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Title != "Meeting 2022-08-12" || string(content) != "# Agenda\n\nfrom stdin\n\n#work" {
+		t.Fatalf("expected the note to be created from the template, got %q and %q", header.Title, content)
+	}
+	// stdin is already read by --stdin, so there is nothing to answer prompts with
+	_, _, err = applyTemplate("client", meta, []byte("from stdin"), true)
+	if err == nil || !strings.Contains(err.Error(), "can not prompt for Customer") {
+		t.Fatalf("expected a template with prompts to be refused, got %v", err)
+	}
+	_, _, err = applyTemplate("missing", meta, nil, false)
+	if err == nil {
+		t.Fatal("expected an error for a template that does not exist")
+	}
+}
+
+func TestAppendCmd(t *testing.T) {
+	store = fss.NewMemory()
+	cfg := config.Default()
+	groceries := saveNote(t, time.Date(2022, 8, 12, 14, 4, 49, 0, time.UTC), "Groceries", "Buy milk #home")
+
+	err := appendCmd(contextOf(t, ":home", "--", "and", "bread", "#weekend"), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, content, err := readNote(groceries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "Buy milk #home\n\nand bread #weekend" || !reflect.DeepEqual(meta.Tags, []string{"home", "weekend"}) {
+		t.Fatalf("expected the text to be appended in a paragraph of its own, got %q and %v", content, meta.Tags)
+	}
+
+	err = appendCmd(contextOf(t, ":home", "--"), cfg)
+	if err == nil || !strings.Contains(err.Error(), "nothing to append") {
+		t.Fatalf("expected an error appending nothing, got %v", err)
+	}
+	err = appendCmd(contextOf(t, ":nothing", "--", "text"), cfg)
+	if err == nil {
+		t.Fatal("expected an error appending to a note that is not found")
+	}
+}
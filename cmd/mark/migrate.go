@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/crholm/mark"
+	"github.com/crholm/mark/internal/config"
+	"github.com/crholm/mark/internal/fss"
+	"github.com/crholm/mark/internal/ts"
+	"github.com/urfave/cli/v2"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// migrateLayout moves notes that are not in the year/month dir given by their filename, e.g. notes created close to
+// midnight at the turn of a month, which were put in the dir of the local time of creation rather than of UTC.
+// The index is by filename, which a move keeps, so it is left as is. The moves are not autocommitted, mark sync
+// commits them
+func migrateLayout(c *cli.Context, cfg config.Config) error {
+	names, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	var moved, problems int
+	for _, name := range names {
+		expected, err := fss.GetFilenameToName(path.Base(name))
+		if err != nil {
+			fmt.Printf("skipping %s since its filename is not a time of creation\n", name)
+			continue
+		}
+		if expected == name {
+			continue
+		}
+		if c.Bool("dry-run") {
+			fmt.Println("would move", name, "->", expected)
+			moved++
+			continue
+		}
+
+		data, err := store.Get(name)
+		if err != nil {
+			return err
+		}
+		err = store.Create(expected, data)
+		if errors.Is(err, fs.ErrExist) {
+			fmt.Printf("can not move %s since %s exists\n", name, expected)
+			problems++
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		err = store.Delete(name)
+		if err != nil {
+			return err
+		}
+		fmt.Println("moved", name, "->", expected)
+		moved++
+	}
+
+	if moved == 0 && problems == 0 {
+		fmt.Println("all notes are in the expected dir")
+	}
+	if problems > 0 {
+		return cli.Exit("", 1)
+	}
+	return nil
+}
+
+// migrateFilenames renames notes to the filename scheme of the config, and rewrites the [[links]] to them and the
+// filenames in the index
+func migrateFilenames(c *cli.Context, cfg config.Config) error {
+	names, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	renamed := map[string]string{}
+	var problems int
+	for _, name := range names {
+		filename, err := fss.GetFilenameInScheme(path.Base(name), cfg.Filenames)
+		if err != nil || filename == path.Base(name) {
+			continue
+		}
+		target := path.Join(path.Dir(name), filename)
+		data, err := store.Get(name)
+		if err != nil {
+			return err
+		}
+		err = store.Create(target, data)
+		if errors.Is(err, fs.ErrExist) {
+			fmt.Printf("can not rename %s since %s exists\n", name, target)
+			problems++
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		renamed[path.Base(name)] = filename
+		// the note is stored under both names, links and the index are still moved to the new one
+		err = store.Delete(name)
+		if err != nil {
+			fmt.Printf("copied %s -> %s, but could not remove it: %v\n", name, filename, err)
+			problems++
+			continue
+		}
+		fmt.Println("renamed", name, "->", filename)
+	}
+	if len(renamed) == 0 {
+		fmt.Println("all notes are named by the", cfg.Filenames, "scheme")
+		return nil
+	}
+
+	// links are to the filename, with or without .md, or to the alias of a note
+	relink := func(target string) (string, bool) {
+		if filename, ok := renamed[target]; ok {
+			return filename, true
+		}
+		if filename, ok := renamed[target+".md"]; ok {
+			return strings.TrimSuffix(filename, ".md"), true
+		}
+		return "", false
+	}
+	err = store.Walk(func(name string, data []byte) error {
+		header, content, err := mark.UnmarshalNote(data)
+		if err != nil {
+			return fmt.Errorf("could not read note %s: %w", name, err)
+		}
+		if header.Encrypted {
+			fmt.Printf("links of %s are not rewritten since it is encrypted\n", name)
+			return nil
+		}
+		relinked := ts.ReplaceLinks(content, relink)
+		if bytes.Equal(relinked, content) {
+			return nil
+		}
+		data, err = mark.MarshalNote(header, relinked)
+		if err != nil {
+			return err
+		}
+		fmt.Println("rewrote links of", name)
+		return store.Put(name, data)
+	})
+	if err != nil {
+		return err
+	}
+
+	index, err := readIndex()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for id, filename := range index.IdToName {
+		if to, ok := renamed[filename]; ok {
+			index.IdToName[id] = to
+		}
+	}
+	data, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	err = store.WriteIndex("index.json", data)
+	if err != nil {
+		return err
+	}
+
+	if problems > 0 {
+		return cli.Exit("", 1)
+	}
+	return nil
+}
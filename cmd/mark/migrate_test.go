@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"github.com/crholm/mark/internal/config"
+	"github.com/crholm/mark/internal/fss"
+	"github.com/urfave/cli/v2"
+	"io/fs"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMigrateFilenames(t *testing.T) {
+	store = fss.NewMemory()
+	created := time.Date(2022, 8, 12, 14, 4, 49, 0, time.UTC)
+	groceries := saveNote(t, created, "Groceries", "Buy milk #home")
+	todo := saveNote(t, created.Add(time.Hour), "Todo", "See [["+path.Base(groceries)+"]] and [["+
+		strings.TrimSuffix(path.Base(groceries), ".md")+"|the list]]")
+	cfg := config.Default()
+	cfg.Filenames = fss.FilenamesPortable
+
+	err := migrateFilenames(contextOf(t), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"2022/08/2022-08-12_14-04-49.000Z_Friday.md",
+		"2022/08/2022-08-12_15-04-49.000Z_Friday.md",
+	}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected the notes to be renamed to %v, got %v", expected, names)
+	}
+	_, content, err := readNote(expected[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "See [[2022-08-12_14-04-49.000Z_Friday.md]] and [[2022-08-12_14-04-49.000Z_Friday|the list]]" {
+		t.Fatalf("expected the links to be rewritten, got %q", content)
+	}
+	index, err := readIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range index.IdToName {
+		if name != path.Base(expected[0]) && name != path.Base(expected[1]) {
+			t.Fatalf("expected the index to be of the new names, got %v", index.IdToName)
+		}
+	}
+	found, err := ls("milk")
+	if err != nil || !reflect.DeepEqual(found, expected[:1]) {
+		t.Fatalf("expected the renamed note to be found, got %v, %v", found, err)
+	}
+
+	// renaming back, where the old names can not be removed
+	store = failingDelete{store}
+	cfg.Filenames = fss.FilenamesColons
+	err = migrateFilenames(contextOf(t), cfg)
+	if err == nil {
+		t.Fatal("expected an error when the old names can not be removed")
+	}
+	names, err = store.List()
+	if err != nil || len(names) != 4 {
+		t.Fatalf("expected the notes to be stored under both names, got %v, %v", names, err)
+	}
+	_, content, err = readNote(todo)
+	if err != nil || !strings.Contains(string(content), "[["+path.Base(groceries)+"]]") {
+		t.Fatalf("expected the links to be rewritten to the new names, got %q, %v", content, err)
+	}
+}
+
+type failingDelete struct {
+	fss.Store
+}
+
+func (s failingDelete) Delete(name string) error {
+	return errors.New("permission denied")
+}
+
+func TestMigrateLayout(t *testing.T) {
+	store = fss.NewMemory()
+	correct := saveNote(t, time.Date(2022, 8, 12, 14, 4, 49, 0, time.UTC), "Groceries", "Buy milk")
+	// created at new year's eve in UTC, but in the dir of the local time of creation, i.e. the next year
+	misfiled := "2023/01/2022-12-31_23:30:00.000Z_Saturday.md"
+	moved := "2022/12/2022-12-31_23:30:00.000Z_Saturday.md"
+	// created at the end of august in UTC, where a note of the same name already is in the dir of august
+	colliding := "2022/09/2022-08-31_23:00:00.000Z_Wednesday.md"
+	existing := "2022/08/2022-08-31_23:00:00.000Z_Wednesday.md"
+	for _, name := range []string{misfiled, colliding, existing} {
+		err := store.Put(name, []byte("---\ntitle: "+name+"\n---\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	before, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := stdoutOf(t, func() error { return migrateLayout(contextOf(t, "--dry-run"), config.Default()) })
+	expected := "would move " + colliding + " -> " + existing + "\nwould move " + misfiled + " -> " + moved + "\n"
+	if out != expected {
+		t.Fatalf("expected a dry run to print\n%s\ngot\n%s", expected, out)
+	}
+	names, err := store.List()
+	if err != nil || !reflect.DeepEqual(names, before) {
+		t.Fatalf("expected a dry run to not move any notes, got %v, %v", names, err)
+	}
+
+	out = stdoutOf(t, func() error {
+		err = migrateLayout(contextOf(t), config.Default())
+		return nil
+	})
+	var exit cli.ExitCoder
+	if !errors.As(err, &exit) || exit.ExitCode() != 1 {
+		t.Fatalf("expected a non zero exit when a note can not be moved, got %v", err)
+	}
+	for _, line := range []string{
+		"can not move " + colliding + " since " + existing + " exists\n",
+		"moved " + misfiled + " -> " + moved + "\n",
+	} {
+		if !strings.Contains(out, line) {
+			t.Fatalf("expected %q in\n%s", line, out)
+		}
+	}
+	names, err = store.List()
+	expectedNames := []string{correct, existing, colliding, moved}
+	if err != nil || !reflect.DeepEqual(names, expectedNames) {
+		t.Fatalf("expected the notes %v, got %v, %v", expectedNames, names, err)
+	}
+	_, err = store.Get(misfiled)
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected the misfiled note to be removed, got %v", err)
+	}
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/crholm/mark/internal/config"
+	"github.com/crholm/mark/internal/fss"
+	"github.com/modfin/henry/mapz"
+	"github.com/modfin/henry/slicez"
+	"github.com/urfave/cli/v2"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+type notebook struct {
+	name string
+	config.Notebook
+}
+
+// storageOf returns the storage dir given by --store or --notebook, or the default one
+func storageOf(c *cli.Context) (string, error) {
+	if store := c.String("store"); len(store) > 0 {
+		return filepath.Abs(store)
+	}
+	name := c.String("notebook")
+	if len(name) == 0 || name == config.DefaultNotebook {
+		return fss.DefaultStoragePath()
+	}
+	notebooks, err := config.LoadNotebooks()
+	if err != nil {
+		return "", err
+	}
+	nb, ok := notebooks[name]
+	if !ok {
+		return "", fmt.Errorf("no notebook named %s, see mark notebooks ls", name)
+	}
+	return nb.Path, nil
+}
+
+// allNotebooks returns the default notebook followed by the registered ones
+func allNotebooks() ([]notebook, error) {
+	path, err := fss.DefaultStoragePath()
+	if err != nil {
+		return nil, err
+	}
+	notebooks, err := config.LoadNotebooks()
+	if err != nil {
+		return nil, err
+	}
+	res := []notebook{{name: config.DefaultNotebook, Notebook: config.Notebook{Path: path}}}
+	for _, name := range slicez.Sort(mapz.Keys(notebooks)) {
+		res = append(res, notebook{name: name, Notebook: notebooks[name]})
+	}
+	return res, nil
+}
+
+// notebookOf returns the name of the notebook a note found by searching all notebooks belongs to
+func notebookOf(name string) string {
+	nb, _, _ := strings.Cut(name, "/")
+	return nb
+}
+
+// search lists the notes matching the query in the current notebook, or in all of them with --all-notebooks, along
+// with the store to read them from. In the latter case it is a store of all notebooks, where notes are named
+// <notebook>/<name>
+func search(c *cli.Context, prefix string) ([]string, fss.Store, error) {
+	if !c.Bool("all-notebooks") {
+		files, err := ls(prefix)
+		return files, store, err
+	}
+
+	if prefix == "-" {
+		line, _, err := bufio.NewReader(os.Stdin).ReadLine()
+		if err != nil {
+			return nil, nil, err
+		}
+		prefix = strings.TrimSpace(string(line))
+	}
+
+	notebooks, err := allNotebooks()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// ls searches the store, which is set to each notebook in turn
+	defer func(s fss.Store) { store = s }(store)
+	stores := notebooksStore{}
+	var files []string
+	for _, nb := range notebooks {
+		store = fss.NewFS(nb.Path)
+		stores[nb.name] = store
+		found, err := ls(prefix)
+		if errors.Is(err, fs.ErrNotExist) { // a notebook without any notes, and thereby no index
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("notebook %s: %w", nb.name, err)
+		}
+		for _, f := range found {
+			files = append(files, nb.name+"/"+f)
+		}
+	}
+	return files, stores, nil
+}
+
+// notebooksStore is the notes of several notebooks, named <notebook>/<name>. Indexes are per notebook and can not
+// be read or written through it
+type notebooksStore map[string]fss.Store
+
+func (s notebooksStore) route(name string) (fss.Store, string, error) {
+	nb, rest, _ := strings.Cut(name, "/")
+	store, ok := s[nb]
+	if !ok {
+		return nil, "", fmt.Errorf("note %s: %w", name, fs.ErrNotExist)
+	}
+	return store, rest, nil
+}
+
+func (s notebooksStore) Get(name string) ([]byte, error) {
+	store, rest, err := s.route(name)
+	if err != nil {
+		return nil, err
+	}
+	return store.Get(rest)
+}
+
+func (s notebooksStore) Put(name string, data []byte) error {
+	store, rest, err := s.route(name)
+	if err != nil {
+		return err
+	}
+	return store.Put(rest, data)
+}
+
+func (s notebooksStore) Create(name string, data []byte) error {
+	store, rest, err := s.route(name)
+	if err != nil {
+		return err
+	}
+	return store.Create(rest, data)
+}
+
+func (s notebooksStore) Delete(name string) error {
+	store, rest, err := s.route(name)
+	if err != nil {
+		return err
+	}
+	return store.Delete(rest)
+}
+
+func (s notebooksStore) List() ([]string, error) {
+	var names []string
+	for _, nb := range slicez.Sort(mapz.Keys(s)) {
+		found, err := s[nb].List()
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range found {
+			names = append(names, nb+"/"+name)
+		}
+	}
+	return names, nil
+}
+
+func (s notebooksStore) Walk(fn func(name string, data []byte) error) error {
+	for _, nb := range slicez.Sort(mapz.Keys(s)) {
+		err := s[nb].Walk(func(name string, data []byte) error {
+			return fn(nb+"/"+name, data)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s notebooksStore) ReadIndex(name string) ([]byte, error) {
+	return nil, errors.New("the indexes of all notebooks can not be read at once")
+}
+
+func (s notebooksStore) WriteIndex(name string, data []byte) error {
+	return errors.New("the indexes of all notebooks can not be written at once")
+}
+
+// GetAsset returns the asset from the first notebook having it, which is fine since assets are named by content
+func (s notebooksStore) GetAsset(name string) ([]byte, error) {
+	for _, nb := range slicez.Sort(mapz.Keys(s)) {
+		data, err := s[nb].GetAsset(name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		return data, err
+	}
+	return nil, fmt.Errorf("asset %s: %w", name, fs.ErrNotExist)
+}
+
+func (s notebooksStore) PutAsset(name string, data []byte) error {
+	return errors.New("assets can not be added to all notebooks at once")
+}
+
+func (s notebooksStore) DeleteAsset(name string) error {
+	return errors.New("assets can not be deleted from all notebooks at once")
+}
+
+func (s notebooksStore) ListAssets() ([]string, error) {
+	var names []string
+	for _, nb := range slicez.Sort(mapz.Keys(s)) {
+		found, err := s[nb].ListAssets()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, found...)
+	}
+	return slicez.Sort(slicez.Uniq(names)), nil
+}
+
+// AssetPath returns the path on disk of an asset, in the first notebook having it
+func (s notebooksStore) AssetPath(name string) string {
+	for _, nb := range slicez.Sort(mapz.Keys(s)) {
+		if _, err := s[nb].GetAsset(name); err == nil {
+			return assetPathOfIn(s[nb], name)
+		}
+	}
+	return name
+}
+
+// Path returns the path on disk of a note
+func (s notebooksStore) Path(name string) string {
+	store, rest, err := s.route(name)
+	if err != nil {
+		return name
+	}
+	return pathOfIn(store, rest)
+}
+
+func notebooksLs(c *cli.Context) error {
+	notebooks, err := allNotebooks()
+	if err != nil {
+		return err
+	}
+	current, err := fss.GetStoragePath()
+	if err != nil {
+		return err
+	}
+	for _, nb := range notebooks {
+		marker := " "
+		if nb.Path == current {
+			marker = "*"
+		}
+		fmt.Printf("%s %-12s %s %s\n", marker, nb.name, nb.Path, nb.Remote)
+	}
+	return nil
+}
+
+func notebooksAdd(c *cli.Context) error {
+	name := c.Args().First()
+	if len(name) == 0 || name == config.DefaultNotebook || strings.ContainsAny(name, `/\:`) {
+		return errors.New("a notebook must be given a name, that is not default and without / \\ or :")
+	}
+	notebooks, err := config.LoadNotebooks()
+	if err != nil {
+		return err
+	}
+	if _, exists := notebooks[name]; exists {
+		return fmt.Errorf("there is already a notebook named %s", name)
+	}
+
+	path := c.Args().Get(1)
+	if len(path) == 0 {
+		path, err = config.DefaultNotebookPath(name)
+		if err != nil {
+			return err
+		}
+	}
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	remote := c.String("remote")
+	_, err = os.Stat(path)
+	switch {
+	case len(remote) > 0 && errors.Is(err, os.ErrNotExist):
+		err = run("", "git", "clone", remote, path)
+	case len(remote) > 0:
+		_, err = os.Stat(filepath.Join(path, ".git"))
+		if errors.Is(err, os.ErrNotExist) {
+			err = run(path, "git", "init")
+			if err == nil {
+				err = run(path, "git", "remote", "add", "origin", remote)
+			}
+		}
+	default:
+		err = os.MkdirAll(path, 0755)
+	}
+	if err != nil {
+		return err
+	}
+
+	notebooks[name] = config.Notebook{Path: path, Remote: remote}
+	err = config.SaveNotebooks(notebooks)
+	if err != nil {
+		return err
+	}
+	fmt.Println("added notebook", name, "in", path)
+	return nil
+}
+
+func notebooksRm(c *cli.Context) error {
+	name := c.Args().First()
+	notebooks, err := config.LoadNotebooks()
+	if err != nil {
+		return err
+	}
+	nb, ok := notebooks[name]
+	if !ok {
+		return fmt.Errorf("no notebook named %s", name)
+	}
+	delete(notebooks, name)
+	err = config.SaveNotebooks(notebooks)
+	if err != nil {
+		return err
+	}
+	fmt.Println("removed notebook", name, "the notes are still kept in", nb.Path)
+	return nil
+}
+
+func run(dir string, name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"github.com/crholm/mark/internal/config"
+	"github.com/crholm/mark/internal/fss"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testNotebooks registers a notebook named work, in a home dir of the test
+func testNotebooks(t *testing.T) (home string, work string) {
+	t.Helper()
+	home = t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	t.Setenv("MARK_HOME", "")
+	work = filepath.Join(home, "work")
+	err := config.SaveNotebooks(config.Notebooks{"work": {Path: work}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return home, work
+}
+
+func TestNotebooks(t *testing.T) {
+	home, work := testNotebooks(t)
+
+	for _, c := range []struct {
+		args     []string
+		expected string
+	}{
+		{nil, filepath.Join(home, ".mark")},
+		{[]string{"--notebook", "default"}, filepath.Join(home, ".mark")},
+		{[]string{"--notebook", "work"}, work},
+		{[]string{"--store", work, "--notebook", "other"}, work},
+	} {
+		dir, err := storageOf(contextOf(t, c.args...))
+		if err != nil || dir != c.expected {
+			t.Fatalf("expected %v to store notes in %s, got %s, %v", c.args, c.expected, dir, err)
+		}
+	}
+	_, err := storageOf(contextOf(t, "--notebook", "other"))
+	if err == nil {
+		t.Fatal("expected an error for a notebook that is not registered")
+	}
+
+	t.Setenv("MARK_HOME", filepath.Join(home, "notes"))
+	dir, err := storageOf(contextOf(t))
+	if err != nil || dir != filepath.Join(home, "notes") {
+		t.Fatalf("expected $MARK_HOME to be the default notebook, got %s, %v", dir, err)
+	}
+
+	notebooks, err := allNotebooks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []notebook{
+		{name: config.DefaultNotebook, Notebook: config.Notebook{Path: filepath.Join(home, "notes")}},
+		{name: "work", Notebook: config.Notebook{Path: work}},
+	}
+	if !reflect.DeepEqual(notebooks, expected) {
+		t.Fatalf("expected %v, got %v", expected, notebooks)
+	}
+	if notebookOf("work/2022/08/2022-08-12_14:04:49.000Z_Friday.md") != "work" {
+		t.Fatal("expected the notebook of a note found in all notebooks")
+	}
+}
+
+func TestSearchAllNotebooks(t *testing.T) {
+	home, work := testNotebooks(t)
+	created := time.Date(2022, 8, 12, 14, 4, 49, 0, time.UTC)
+	store = fss.NewFS(filepath.Join(home, ".mark"))
+	groceries := saveNote(t, created, "Groceries", "Buy milk #home")
+	store = fss.NewFS(work)
+	meeting := saveNote(t, created.Add(time.Hour), "Meeting", "Buy a projector #work")
+
+	current := store
+	files, found, err := search(contextOf(t, "--all-notebooks"), "buy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store != current {
+		t.Fatal("expected the store to be left as is")
+	}
+	expected := []string{config.DefaultNotebook + "/" + groceries, "work/" + meeting}
+	if !reflect.DeepEqual(files, expected) {
+		t.Fatalf("expected %v, got %v", expected, files)
+	}
+	data, err := found.Get("work/" + meeting)
+	if err != nil || !strings.Contains(string(data), "Buy a projector") {
+		t.Fatalf("expected the notes to be read from the store of all notebooks, got %q, %v", data, err)
+	}
+
+	files, found, err = search(contextOf(t), "buy")
+	if err != nil || found != current || !reflect.DeepEqual(files, []string{meeting}) {
+		t.Fatalf("expected only the notes of the current notebook, got %v, %v", files, err)
+	}
+}
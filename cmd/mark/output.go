@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/crholm/mark"
+	"github.com/crholm/mark/internal/fss"
+	"github.com/urfave/cli/v2"
+	"io"
+	"path"
+)
+
+type noteJSON struct {
+	File string `json:"file"`
+	Path string `json:"path"`
+	mark.Header
+	Body *string `json:"body,omitempty"`
+}
+
+// pathOf returns the path on disk of a note, if the store has one
+func pathOf(name string) string {
+	return pathOfIn(store, name)
+}
+
+func pathOfIn(s fss.Store, name string) string {
+	if p, ok := s.(interface{ Path(string) string }); ok {
+		return p.Path(name)
+	}
+	return name
+}
+
+// assetPathOf returns the path on disk of an asset, if the store has one
+func assetPathOf(name string) string {
+	return assetPathOfIn(store, name)
+}
+
+func assetPathOfIn(s fss.Store, name string) string {
+	if p, ok := s.(interface{ AssetPath(string) string }); ok {
+		return p.AssetPath(name)
+	}
+	return path.Join("assets", name)
+}
+
+// checkOutput returns an error if --output is neither text nor json
+func checkOutput(c *cli.Context) error {
+	switch c.String("output") {
+	case "", "text", "json":
+		return nil
+	}
+	return fmt.Errorf("unknown output %s, expected text or json", c.String("output"))
+}
+
+func jsonOutput(c *cli.Context) bool {
+	return c.String("output") == "json"
+}
+
+// writeNotesJSON writes a json array of the header of each note, and its body if withBody is set
+func writeNotesJSON(w io.Writer, files []string, withBody bool) error {
+	notes := []noteJSON{}
+	for _, f := range files {
+		n := noteJSON{File: path.Base(f), Path: pathOf(f)}
+		var err error
+		if withBody {
+			var content []byte
+			n.Header, content, err = readNote(f)
+			body := string(content)
+			n.Body = &body
+		} else {
+			n.Header, err = readHeader(f)
+		}
+		if err != nil {
+			return err
+		}
+		if n.Tags == nil {
+			n.Tags = []string{}
+		}
+		notes = append(notes, n)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return enc.Encode(notes)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/crholm/mark/internal/fss"
+	"github.com/modfin/henry/mapz"
+	"github.com/modfin/henry/slicez"
+	"path"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWriteNotesJSON(t *testing.T) {
+	store = fss.NewMemory()
+	groceries := saveNote(t, time.Date(2022, 8, 12, 14, 4, 49, 0, time.UTC), "Groceries", "Buy milk #home")
+	todo := saveNote(t, time.Date(2022, 8, 13, 14, 4, 49, 0, time.UTC), "Todo", "Nothing to do")
+
+	fields := []string{"alias", "created_at", "file", "path", "tags", "title", "updated_at"}
+	for _, withBody := range []bool{false, true} {
+		buf := bytes.NewBuffer(nil)
+		err := writeNotesJSON(buf, []string{groceries, todo}, withBody)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var notes []map[string]interface{}
+		err = json.Unmarshal(buf.Bytes(), &notes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(notes) != 2 {
+			t.Fatalf("expected 2 notes, got %s", buf)
+		}
+
+		expected := fields
+		if withBody {
+			expected = slicez.Sort(append([]string{"body"}, fields...))
+		}
+		for _, n := range notes {
+			if keys := slicez.Sort(mapz.Keys(n)); !reflect.DeepEqual(keys, expected) {
+				t.Fatalf("expected the fields %v, got %v", expected, keys)
+			}
+		}
+		if notes[0]["file"] != path.Base(groceries) || notes[0]["path"] != groceries || notes[0]["title"] != "Groceries" {
+			t.Fatalf("expected the file, path and title of the note, got %v", notes[0])
+		}
+		if !reflect.DeepEqual(notes[0]["tags"], []interface{}{"home"}) || !reflect.DeepEqual(notes[1]["tags"], []interface{}{}) {
+			t.Fatalf("expected the tags of the notes, as [] when there are none, got %v and %v", notes[0]["tags"], notes[1]["tags"])
+		}
+		if withBody && notes[0]["body"] != "Buy milk #home" {
+			t.Fatalf("expected the body of the note, got %v", notes[0]["body"])
+		}
+	}
+
+	buf := bytes.NewBuffer(nil)
+	err := writeNotesJSON(buf, nil, false)
+	if err != nil || buf.String() != "[]\n" {
+		t.Fatalf("expected an empty array without notes, got %q, %v", buf, err)
+	}
+}
+
+func TestJSONOutput(t *testing.T) {
+	for _, c := range []struct {
+		args     []string
+		expected bool
+	}{
+		{nil, false},
+		{[]string{"--output", "text"}, false},
+		{[]string{"--output", "json"}, true},
+	} {
+		if jsonOutput(contextOf(t, c.args...)) != c.expected {
+			t.Fatalf("expected json output to be %v given %v", c.expected, c.args)
+		}
+	}
+}
+
+func TestCheckOutput(t *testing.T) {
+	for _, c := range []struct {
+		args  []string
+		valid bool
+	}{
+		{nil, true},
+		{[]string{"--output", "text"}, true},
+		{[]string{"--output", "json"}, true},
+		{[]string{"--output", "yaml"}, false},
+		{[]string{"--output", "JSON"}, false},
+	} {
+		err := checkOutput(contextOf(t, c.args...))
+		if (err == nil) != c.valid {
+			t.Fatalf("expected %v to be valid %v, got %v", c.args, c.valid, err)
+		}
+	}
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/crholm/mark"
+	"github.com/crholm/mark/internal/config"
+	"github.com/crholm/mark/internal/fss"
+	"github.com/crholm/mark/internal/git"
+	"github.com/crholm/mark/internal/merge"
+	"github.com/crholm/mark/internal/ts"
+	"github.com/modfin/henry/slicez"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// indexFiles are rebuilt from the notes after each pull rather than synced, since they can not be merged
+var indexFiles = []string{"index.json", "index.tsar"}
+
+func syncCmd(cfg config.Config) error {
+	r, err := storageRepo()
+	if err != nil {
+		return err
+	}
+	return syncNotes(r, cfg)
+}
+
+// storageRepo returns a git runner for the storage dir of the notebook
+func storageRepo() (git.Runner, error) {
+	dir, err := fss.GetStoragePath()
+	return git.New(dir), err
+}
+
+// syncNotes commits all changes of the notes, pulls the changes of others and pushes, stopping at the first step
+// that fails. Notes changed on both sides are merged, see resolveConflicts, and the indexes are rebuilt once pulled
+func syncNotes(r git.Runner, cfg config.Config) error {
+	_, err := r.Status()
+	if errors.Is(err, git.ErrNotInstalled) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("the notes at %s are not in a git repo, run mark git init or add the notebook with --remote: %w", r.Dir, err)
+	}
+	err = ignoreIndexes(r)
+	if err != nil {
+		return fmt.Errorf("could not ignore the indexes: %w", err)
+	}
+	changes, err := r.Status()
+	if err != nil {
+		return err
+	}
+
+	if len(changes) > 0 {
+		err = r.AddAll()
+		if err != nil {
+			return fmt.Errorf("could not add the changes: %w", err)
+		}
+		message := commitMessage(changes, cfg.Sync.Message)
+		err = r.Commit(message)
+		if err != nil {
+			return fmt.Errorf("could not commit the changes: %w", err)
+		}
+		fmt.Println("committed", strings.SplitN(message, "\n", 2)[0])
+	} else {
+		fmt.Println("nothing to commit")
+	}
+
+	remote, branch, ok, err := syncTarget(r, cfg)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		fmt.Println("no remote to sync with, set sync.remote in the config or an upstream of the branch")
+		return nil
+	}
+
+	// the branch does not exist on a new remote until it is pushed to the first time
+	if len(remote) == 0 || r.HasRemoteBranch(remote, branch) {
+		err = r.Pull(remote, branch)
+		if err != nil {
+			conflicts, cerr := r.Conflicts()
+			if cerr != nil || len(conflicts) == 0 {
+				return fmt.Errorf("could not pull: %w", err)
+			}
+			err = mergeConflicts(r, conflicts)
+			if err != nil {
+				return err
+			}
+		}
+		fmt.Println("pulled")
+		err = reindexAll(cfg)
+		if err != nil {
+			return fmt.Errorf("could not rebuild the indexes: %w", err)
+		}
+	}
+	err = r.Push(remote, branch)
+	if err != nil {
+		return fmt.Errorf("could not push: %w", err)
+	}
+	fmt.Println("pushed")
+	return nil
+}
+
+// syncTarget returns the remote and branch to sync with, which are empty for the upstream of the current branch.
+// It reports false if there is neither a remote in the config nor an upstream
+func syncTarget(r git.Runner, cfg config.Config) (remote string, branch string, ok bool, err error) {
+	remote, branch = cfg.Sync.Remote, cfg.Sync.Branch
+	if len(remote) == 0 {
+		_, err = r.Upstream()
+		return "", "", err == nil, nil
+	}
+	if len(branch) == 0 {
+		branch, err = r.Branch()
+		if err != nil {
+			return "", "", false, fmt.Errorf("could not find the current branch: %w", err)
+		}
+	}
+	return remote, branch, true, nil
+}
+
+// ignoreIndexes adds the indexes to the .gitignore of the storage dir and removes them from the repo, if tracked
+func ignoreIndexes(r git.Runner) error {
+	file := filepath.Join(r.Dir, ".gitignore")
+	data, err := os.ReadFile(file)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	lines := strings.Split(string(data), "\n")
+	var missing []string
+	// the indexes are written to temporary files, e.g. .index.json.123, before replacing them
+	for _, pattern := range append(slicez.Map(indexFiles, func(f string) string { return "/" + f }), "/.index.*") {
+		if !slicez.Contains(lines, pattern) {
+			missing = append(missing, pattern)
+		}
+	}
+	if len(missing) > 0 {
+		if len(data) > 0 && !bytes.HasSuffix(data, []byte("\n")) {
+			data = append(data, '\n')
+		}
+		data = append(data, strings.Join(missing, "\n")+"\n"...)
+		err = os.WriteFile(file, data, 0644)
+		if err != nil {
+			return err
+		}
+	}
+	return r.Untrack(indexFiles...)
+}
+
+// mergeConflicts resolves the files a pull left unmerged, commits the merge and reports the notes that needed it
+func mergeConflicts(r git.Runner, conflicts []string) error {
+	resolved, err := resolveConflicts(r, conflicts)
+	if err != nil {
+		return fmt.Errorf("could not merge the notes: %w", err)
+	}
+	err = r.AddAll()
+	if err != nil {
+		return fmt.Errorf("could not add the merged notes: %w", err)
+	}
+	message := "sync: merge"
+	if len(resolved) > 0 {
+		message += "\n\n" + strings.Join(resolved, "\n")
+	}
+	err = r.Commit(message)
+	if err != nil {
+		return fmt.Errorf("could not commit the merge: %w", err)
+	}
+	if len(resolved) > 0 {
+		fmt.Println("notes changed on both sides:")
+		for _, line := range resolved {
+			fmt.Println("  " + line)
+		}
+	}
+	return nil
+}
+
+// resolveConflicts resolves the files left unmerged in the working tree, to be added. Notes are merged, see
+// merge.Note, and if both changed the same lines of the body, their version is kept as a conflict copy. A note
+// removed on one side and edited on the other is kept. Indexes are left untracked and other files are kept as ours.
+// It returns a line per note that needed attention
+func resolveConflicts(r git.Runner, conflicts []string) ([]string, error) {
+	var resolved []string
+	for _, p := range conflicts {
+		if slicez.Contains(indexFiles, p) {
+			err := r.Untrack(p)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		base, ours, theirs, err := r.Unmerged(p)
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimPrefix(p, "lib/")
+		if name == p || !fss.ValidName(name) {
+			kept := ours
+			if kept == nil {
+				kept = theirs
+			}
+			err = writeWorkingFile(r, p, kept)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		switch {
+		case ours == nil && theirs == nil:
+			err = writeWorkingFile(r, p, nil)
+		case ours == nil:
+			err = store.Put(name, theirs)
+			resolved = append(resolved, fmt.Sprintf("%-8s %s, removed here but edited elsewhere", "kept", noteTitle(name, theirs)))
+		case theirs == nil:
+			err = store.Put(name, ours)
+			resolved = append(resolved, fmt.Sprintf("%-8s %s, edited here but removed elsewhere", "kept", noteTitle(name, ours)))
+		default:
+			var line string
+			line, err = mergeNote(r, name, base, ours, theirs)
+			resolved = append(resolved, line)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return resolved, nil
+}
+
+// conflictTag is the tag of the copies of notes saved when both sides changed their bodies in a way that clashed
+const conflictTag = "conflict"
+
+// mergeNote merges two versions of a note into the note and, if their bodies clashed, saves theirs as a new note
+func mergeNote(r git.Runner, name string, base []byte, ours []byte, theirs []byte) (string, error) {
+	res, err := merge.Note(base, ours, theirs, r.MergeFile)
+	if err != nil {
+		// e.g. a broken header, which can only be fixed by hand
+		return fmt.Sprintf("%-8s %s, could not merge it: %v", "kept", noteTitle(name, ours), err), store.Put(name, ours)
+	}
+	data, err := mark.MarshalNote(res.Header, res.Content)
+	if err != nil {
+		return "", err
+	}
+	err = store.Put(name, data)
+	if err != nil {
+		return "", err
+	}
+	if !res.Conflict {
+		return fmt.Sprintf("%-8s %s", "merged", noteTitle(name, data)), nil
+	}
+
+	// the copy is tagged #conflict to be found, and has no alias to not take the links to the note
+	meta := res.Theirs
+	meta.Title = strings.TrimSpace(meta.Title + " (conflict copy)")
+	meta.Alias = ""
+	meta.Tags = slicez.Uniq(append(meta.Tags, conflictTag))
+	meta.CreatedAt = time.Now().UTC()
+	content := res.TheirsContent
+	if !meta.Encrypted {
+		content = ts.EnsureTags(content, []string{conflictTag})
+	}
+	copyName, _, err := fss.SaveNote(store, meta, content)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%-8s %s, their version is in %s", "conflict", noteTitle(name, data), path.Base(copyName)), nil
+}
+
+// noteTitle returns the title of a note along with the filename, e.g. Groceries (2022-08-12_14:04:49.000Z_Friday.md)
+func noteTitle(name string, data []byte) string {
+	header, _, err := mark.UnmarshalNote(data)
+	if err != nil || len(strings.TrimSpace(header.Title)) == 0 {
+		return path.Base(name)
+	}
+	return fmt.Sprintf("%s (%s)", strings.TrimSpace(header.Title), path.Base(name))
+}
+
+// writeWorkingFile writes a file of the working tree, or removes it if data is nil
+func writeWorkingFile(r git.Runner, p string, data []byte) error {
+	file := filepath.Join(r.Dir, filepath.FromSlash(p))
+	if data == nil {
+		err := os.Remove(file)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	return os.WriteFile(file, data, 0644)
+}
+
+// commitMessage summarizes the added, edited and removed notes, e.g. "sync: 1 added, 2 edited", followed by a line
+// per note. The subject is the message of the config, if set
+func commitMessage(changes []git.Change, subject string) string {
+	var added, edited, removed, other int
+	var lines []string
+	for _, c := range changes {
+		name := strings.TrimPrefix(c.Path, "lib/")
+		if name == c.Path || path.Ext(name) != ".md" {
+			other++
+			continue
+		}
+		var verb string
+		switch c.Status {
+		case git.Added:
+			verb = "added"
+			added++
+		case git.Deleted:
+			verb = "removed"
+			removed++
+		default:
+			verb = "edited"
+			edited++
+		}
+		title := ""
+		if c.Status != git.Deleted {
+			if header, err := readHeader(name); err == nil {
+				title = strings.TrimSpace(header.Title)
+			}
+		}
+		if len(title) > 0 {
+			lines = append(lines, fmt.Sprintf("%-8s %s (%s)", verb, title, path.Base(name)))
+		} else {
+			lines = append(lines, fmt.Sprintf("%-8s %s", verb, path.Base(name)))
+		}
+	}
+
+	if len(subject) == 0 {
+		var counts []string
+		for _, c := range []struct {
+			n    int
+			verb string
+		}{{added, "added"}, {edited, "edited"}, {removed, "removed"}} {
+			if c.n > 0 {
+				counts = append(counts, fmt.Sprintf("%d %s", c.n, c.verb))
+			}
+		}
+		if len(counts) == 0 && other == 1 {
+			counts = append(counts, "1 other file")
+		} else if len(counts) == 0 {
+			counts = append(counts, fmt.Sprintf("%d other files", other))
+		}
+		subject = "sync: " + strings.Join(counts, ", ")
+	}
+	if len(lines) == 0 {
+		return subject
+	}
+	return subject + "\n\n" + strings.Join(lines, "\n")
+}
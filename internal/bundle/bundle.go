@@ -0,0 +1,256 @@
+package bundle
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/crholm/mark"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// Note is a note file as stored on disk, with its path relative to the lib dir, e.g. 2022/08/2022-08-12_14:04:49Z_Friday.md,
+// or an asset attached to notes, with its path in the assets dir, e.g. assets/<sha256>.png, see AssetPath
+type Note struct {
+	Path string
+	Data []byte
+}
+
+// Record is a note as a json lines record. Unlike tar and zip, which keep the note file as is, the header and body
+// are stored as fields, so a note is read back as mark saves notes: the header marshalled anew and the body trimmed,
+// ending with a single newline
+type Record struct {
+	File string `json:"file"`
+	Path string `json:"path"`
+	mark.Header
+	Body string `json:"body"`
+}
+
+// assetRecord is an asset as a json lines record, the data is base64 encoded
+type assetRecord struct {
+	File string `json:"file"`
+	Path string `json:"path"`
+	Data []byte `json:"data"`
+}
+
+const assetsDir = "assets/"
+
+// AssetPath returns the path in a bundle of an asset
+func AssetPath(name string) string {
+	return assetsDir + name
+}
+
+// IsAsset reports whether p is the path of an asset and returns its name
+func IsAsset(p string) (string, bool) {
+	name := strings.TrimPrefix(p, assetsDir)
+	if name == p || len(name) == 0 || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return "", false
+	}
+	return name, true
+}
+
+func Formats() []string {
+	return []string{"jsonl", "tar", "zip"}
+}
+
+func Write(w io.Writer, format string, notes []Note) error {
+	switch format {
+	case "jsonl":
+		return writeJSONL(w, notes)
+	case "tar":
+		return writeTar(w, notes)
+	case "zip":
+		return writeZip(w, notes)
+	default:
+		return fmt.Errorf("unknown bundle format %s, expected one of %s", format, strings.Join(Formats(), ", "))
+	}
+}
+
+func Read(r io.Reader, format string) ([]Note, error) {
+	var notes []Note
+	var err error
+	switch format {
+	case "jsonl":
+		notes, err = readJSONL(r)
+	case "tar":
+		notes, err = readTar(r)
+	case "zip":
+		notes, err = readZip(r)
+	default:
+		return nil, fmt.Errorf("unknown bundle format %s, expected one of %s", format, strings.Join(Formats(), ", "))
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range notes {
+		_, asset := IsAsset(n.Path)
+		if !asset && !ValidPath(n.Path) {
+			return nil, fmt.Errorf("invalid path %s in bundle, must be a relative path of a .md file or an asset", n.Path)
+		}
+	}
+	return notes, nil
+}
+
+// ValidPath reports whether p is a relative path, within the lib dir, of a note
+func ValidPath(p string) bool {
+	clean := path.Clean(p)
+	return clean == p && !path.IsAbs(p) && !strings.HasPrefix(p, "../") && path.Ext(p) == ".md"
+}
+
+func writeJSONL(w io.Writer, notes []Note) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	for _, n := range notes {
+		if name, ok := IsAsset(n.Path); ok {
+			err := enc.Encode(assetRecord{File: name, Path: n.Path, Data: n.Data})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		header, body, err := mark.UnmarshalNote(n.Data)
+		if err != nil {
+			return fmt.Errorf("could not read %s: %w", n.Path, err)
+		}
+		err = enc.Encode(Record{
+			File:   path.Base(n.Path),
+			Path:   n.Path,
+			Header: header,
+			Body:   string(body),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readJSONL(r io.Reader) ([]Note, error) {
+	var notes []Note
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for i := 1; scanner.Scan(); i++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var rec Record
+		err := json.Unmarshal(line, &rec)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i, err)
+		}
+		if len(rec.Path) == 0 {
+			return nil, fmt.Errorf("line %d: record has no path", i)
+		}
+		if _, ok := IsAsset(rec.Path); ok {
+			var asset assetRecord
+			err = json.Unmarshal(line, &asset)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i, err)
+			}
+			notes = append(notes, Note{Path: asset.Path, Data: asset.Data})
+			continue
+		}
+		data, err := mark.MarshalNote(rec.Header, []byte(rec.Body))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i, err)
+		}
+		notes = append(notes, Note{Path: rec.Path, Data: data})
+	}
+	return notes, scanner.Err()
+}
+
+func writeTar(w io.Writer, notes []Note) error {
+	tw := tar.NewWriter(w)
+	for _, n := range notes {
+		err := tw.WriteHeader(&tar.Header{
+			Name:    n.Path,
+			Mode:    0644,
+			Size:    int64(len(n.Data)),
+			ModTime: time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+		_, err = tw.Write(n.Data)
+		if err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+func readTar(r io.Reader) ([]Note, error) {
+	var notes []Note
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return notes, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if h.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, Note{Path: h.Name, Data: data})
+	}
+}
+
+func writeZip(w io.Writer, notes []Note) error {
+	zw := zip.NewWriter(w)
+	for _, n := range notes {
+		f, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     n.Path,
+			Method:   zip.Deflate,
+			Modified: time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(n.Data)
+		if err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func readZip(r io.Reader) ([]Note, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	var notes []Note
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(rc)
+		_ = rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, Note{Path: f.Name, Data: data})
+	}
+	return notes, nil
+}
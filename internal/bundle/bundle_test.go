@@ -0,0 +1,76 @@
+package bundle
+
+import (
+	"bytes"
+	"github.com/crholm/mark"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRoundTrip(t *testing.T) {
+	var notes []Note
+	for i, content := range []string{"a note #tag", "# Heading\n\nwith [[links]] and\n\nparagraphs", ""} {
+		header := mark.Header{
+			Title:     "Note",
+			Alias:     "alias",
+			Tags:      []string{"tag"},
+			CreatedAt: time.Date(2022, 8, 12, 14, 4, 49+i, 0, time.UTC),
+			UpdatedAt: time.Date(2022, 8, 13, 14, 4, 49, 123456789, time.UTC),
+			Extra:     map[string]interface{}{"status": "open"},
+		}
+		data, err := mark.MarshalNote(header, []byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		notes = append(notes, Note{Path: "2022/08/" + header.CreatedAt.Format("2006-01-02_15:04:05Z0700_Monday.md"), Data: data})
+	}
+	notes = append(notes, Note{Path: AssetPath("8f8cbb7dcf46e0bc7d53265749a6c17d116093a6ba95e442764060c76fd4a86c.png"), Data: []byte{0x89, 'P', 'N', 'G', 0}})
+
+	for _, format := range Formats() {
+		buf := bytes.NewBuffer(nil)
+		err := Write(buf, format, notes)
+		if err != nil {
+			t.Fatalf("%s: could not write bundle: %v", format, err)
+		}
+		res, err := Read(buf, format)
+		if err != nil {
+			t.Fatalf("%s: could not read bundle: %v", format, err)
+		}
+		if !reflect.DeepEqual(notes, res) {
+			t.Fatalf("%s: expected\n%v\ngot\n%v", format, notes, res)
+		}
+	}
+}
+
+func TestJSONLNormalizes(t *testing.T) {
+	data := []byte("---\ntitle:   Note\ntags: [tag]\n---\n\n  a note #tag\n\n\n")
+	buf := bytes.NewBuffer(nil)
+	err := Write(buf, "jsonl", []Note{{Path: "2022/08/2022-08-12_14:04:49Z_Friday.md", Data: data}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Read(buf, "jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := mark.MarshalNote(mark.Header{Title: "Note", Tags: []string{"tag"}}, []byte("a note #tag"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || !bytes.Equal(res[0].Data, expected) {
+		t.Fatalf("expected the note to be read back as mark saves it\n%q\ngot\n%v", expected, res)
+	}
+}
+
+func TestReadInvalidPath(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	err := Write(buf, "tar", []Note{{Path: "../../.bashrc.md", Data: []byte("---\n---\n")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Read(buf, "tar")
+	if err == nil {
+		t.Fatal("expected an error for a path outside of lib")
+	}
+}
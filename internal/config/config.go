@@ -0,0 +1,124 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Config is read from ~/.config/mark/config.yaml and then .mark/config.yaml of the storage, where the latter
+// takes precedence. Environment variables, i.e. $EDITOR, $PAGER, $MARK_PICKER and so on, overrides both
+type Config struct {
+	Editor     string `yaml:"editor"`
+	Pager      string `yaml:"pager"`
+	Picker     string `yaml:"picker"`
+	PickerMode string `yaml:"picker_mode"` // file or grep
+	Format     string `yaml:"format"`
+	Encrypt    bool   `yaml:"encrypt"`   // encrypts the body of new notes, e.g. set in .mark/config.yaml of a notebook
+	Filenames  string `yaml:"filenames"` // colons, or portable for filenames without colons, e.g. for windows
+
+	Printer   Printer   `yaml:"printer"`
+	Tokenizer Tokenizer `yaml:"tokenizer"`
+	Sync      Sync      `yaml:"sync"`
+}
+
+type Printer struct {
+	Width      int    `yaml:"width"` // 0 is the width of the terminal
+	Style      string `yaml:"style"`
+	DateLayout string `yaml:"date_layout"`
+}
+
+type Tokenizer struct {
+	MinLength int      `yaml:"min_length"`
+	StopWords []string `yaml:"stop_words"`
+}
+
+type Sync struct {
+	Remote  string `yaml:"remote"`  // defaults to the upstream of the current branch
+	Branch  string `yaml:"branch"`  // defaults to the current branch
+	Message string `yaml:"message"` // defaults to a summary of the added, edited and removed notes
+
+	Autocommit bool `yaml:"autocommit"` // commits each change of a note made by mark, e.g. "edit: Groceries"
+	Autopush   bool `yaml:"autopush"`   // pushes autocommits in the background, retrying with backoff
+}
+
+func Default() Config {
+	return Config{
+		Editor:     "nano",
+		Pager:      "less -r",
+		PickerMode: "file",
+		Format:     "markdown",
+		Filenames:  "colons",
+	}
+}
+
+// UserPath returns the path of the config file of the user, in $XDG_CONFIG_HOME or ~/.config
+func UserPath() (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if len(dir) == 0 {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "mark", "config.yaml"), nil
+}
+
+// StoragePath returns the path of the config file of a storage dir, e.g. ~/.mark
+func StoragePath(storage string) string {
+	return filepath.Join(storage, "config.yaml")
+}
+
+// Load reads the config of the user and of the storage dir on top of the defaults, missing files are ignored
+func Load(storage string) (Config, error) {
+	cfg := Default()
+	user, err := UserPath()
+	if err != nil {
+		return cfg, err
+	}
+	for _, file := range []string{user, StoragePath(storage)} {
+		err = read(file, &cfg)
+		if err != nil {
+			return cfg, err
+		}
+	}
+	cfg.env()
+	return cfg, nil
+}
+
+func read(file string, cfg *Config) error {
+	data, err := ioutil.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	err = yaml.Unmarshal(data, cfg)
+	if err != nil {
+		return fmt.Errorf("could not read config %s: %w", file, err)
+	}
+	return nil
+}
+
+func (cfg *Config) env() {
+	for _, e := range []struct {
+		name  string
+		value *string
+	}{
+		{"EDITOR", &cfg.Editor},
+		{"PAGER", &cfg.Pager},
+		{"MARK_PICKER", &cfg.Picker},
+		{"MARK_PICKER_MODE", &cfg.PickerMode},
+		{"MARK_STYLE", &cfg.Printer.Style},
+		{"MARK_DATE_LAYOUT", &cfg.Printer.DateLayout},
+	} {
+		if v := os.Getenv(e.name); len(v) > 0 {
+			*e.value = v
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "config"))
+	for _, name := range []string{"EDITOR", "PAGER", "MARK_PICKER", "MARK_PICKER_MODE", "MARK_STYLE", "MARK_DATE_LAYOUT"} {
+		t.Setenv(name, "")
+	}
+	storage := filepath.Join(home, ".mark")
+
+	cfg, err := Load(storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Editor != "nano" || cfg.Format != "markdown" || cfg.PickerMode != "file" {
+		t.Fatalf("expected the defaults without config files, got %+v", cfg)
+	}
+
+	user, err := UserPath()
+	if err != nil || user != filepath.Join(home, "config", "mark", "config.yaml") {
+		t.Fatalf("expected the config of the user in $XDG_CONFIG_HOME, got %s, %v", user, err)
+	}
+	write := func(file string, content string) {
+		t.Helper()
+		err := os.MkdirAll(filepath.Dir(file), 0755)
+		if err == nil {
+			err = os.WriteFile(file, []byte(content), 0644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(user, "editor: vim\nformat: plain\npicker_mode: grep\nprinter:\n  width: 80\n")
+	write(StoragePath(storage), "editor: emacs\nformat: raw\nprinter:\n  style: light\n")
+	t.Setenv("EDITOR", "ed")
+	t.Setenv("MARK_STYLE", "dark")
+
+	cfg, err = Load(storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		name     string
+		value    interface{}
+		expected interface{}
+	}{
+		{"pager", cfg.Pager, "less -r"},         // default
+		{"format", cfg.Format, "raw"},           // storage over user
+		{"picker_mode", cfg.PickerMode, "grep"}, // user
+		{"width", cfg.Printer.Width, 80},        // user, next to a style in the storage
+		{"style", cfg.Printer.Style, "dark"},    // env over storage
+		{"editor", cfg.Editor, "ed"},            // env over storage and user
+	} {
+		if c.value != c.expected {
+			t.Fatalf("expected %s to be %v, got %v", c.name, c.expected, c.value)
+		}
+	}
+
+	write(StoragePath(storage), "editor: [vim")
+	_, err = Load(storage)
+	if err == nil {
+		t.Fatalf("expected an invalid config to be an error")
+	}
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// DefaultNotebook is the name of the notebook stored in $MARK_HOME or ~/.mark, which is not part of the registry
+const DefaultNotebook = "default"
+
+// Notebook is a named storage dir, with its own lib/, indexes, config and git repo
+type Notebook struct {
+	Path   string `yaml:"path"`
+	Remote string `yaml:"remote,omitempty"`
+}
+
+// Notebooks is the registry of named notebooks, kept in ~/.config/mark/notebooks.yaml
+type Notebooks map[string]Notebook
+
+func NotebooksPath() (string, error) {
+	user, err := UserPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(user), "notebooks.yaml"), nil
+}
+
+// DefaultNotebookPath returns where a notebook is stored unless a path is given, i.e. ~/.mark-notebooks/<name>
+func DefaultNotebookPath(name string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".mark-notebooks", name), nil
+}
+
+func LoadNotebooks() (Notebooks, error) {
+	notebooks := Notebooks{}
+	file, err := NotebooksPath()
+	if err != nil {
+		return notebooks, err
+	}
+	data, err := ioutil.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return notebooks, nil
+	}
+	if err != nil {
+		return notebooks, err
+	}
+	err = yaml.Unmarshal(data, &notebooks)
+	if err != nil {
+		return notebooks, fmt.Errorf("could not read notebooks %s: %w", file, err)
+	}
+	return notebooks, nil
+}
+
+func SaveNotebooks(notebooks Notebooks) error {
+	file, err := NotebooksPath()
+	if err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(notebooks)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(file), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, data, 0644)
+}
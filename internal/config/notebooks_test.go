@@ -0,0 +1,42 @@
+package config
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNotebooks(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "config"))
+
+	notebooks, err := LoadNotebooks()
+	if err != nil || len(notebooks) != 0 {
+		t.Fatalf("expected no notebooks without a registry, got %v, %v", notebooks, err)
+	}
+
+	path, err := DefaultNotebookPath("work")
+	if err != nil || path != filepath.Join(home, ".mark-notebooks", "work") {
+		t.Fatalf("expected the notebook to be stored in ~/.mark-notebooks/work, got %s, %v", path, err)
+	}
+	expected := Notebooks{
+		"work":     {Path: path, Remote: "git@example.com:notes.git"},
+		"personal": {Path: "/notes/personal"},
+	}
+	err = SaveNotebooks(expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := NotebooksPath()
+	if err != nil || file != filepath.Join(home, "config", "mark", "notebooks.yaml") {
+		t.Fatalf("expected the registry next to the config of the user, got %s, %v", file, err)
+	}
+	notebooks, err = LoadNotebooks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(notebooks, expected) {
+		t.Fatalf("expected %v, got %v", expected, notebooks)
+	}
+}
@@ -0,0 +1,111 @@
+package crypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/scrypt"
+	"io"
+)
+
+const begin = "-----BEGIN MARK ENCRYPTED NOTE-----"
+const end = "-----END MARK ENCRYPTED NOTE-----"
+
+const version = 1
+const saltSize = 16
+
+// ErrPassphrase is returned when a body can not be decrypted, which most likely is due to the wrong passphrase
+var ErrPassphrase = errors.New("could not decrypt, wrong passphrase?")
+
+// key derives a 256 bit key from the passphrase using scrypt, with the parameters recommended for interactive logins
+func key(passphrase string, salt []byte) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), salt, 32768, 8, 1, 32)
+}
+
+// Encrypt encrypts the body of a note using AES-256-GCM, with a key derived from the passphrase and a random salt.
+// The result is armored, i.e. base64 between BEGIN and END lines, so that it can be stored in the markdown file
+func Encrypt(body []byte, passphrase string) ([]byte, error) {
+	salt := make([]byte, saltSize)
+	_, err := io.ReadFull(rand.Reader, salt)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := gcmOf(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	blob := append([]byte{version}, salt...)
+	blob = append(blob, nonce...)
+	blob = gcm.Seal(blob, nonce, body, nil)
+
+	encoded := base64.StdEncoding.EncodeToString(blob)
+	var buf bytes.Buffer
+	buf.WriteString(begin + "\n")
+	for len(encoded) > 64 {
+		buf.WriteString(encoded[:64] + "\n")
+		encoded = encoded[64:]
+	}
+	buf.WriteString(encoded + "\n")
+	buf.WriteString(end)
+	return buf.Bytes(), nil
+}
+
+// Decrypt decrypts a body encrypted by Encrypt
+func Decrypt(armored []byte, passphrase string) ([]byte, error) {
+	armored = bytes.TrimSpace(armored)
+	if !IsEncrypted(armored) || !bytes.HasSuffix(armored, []byte(end)) {
+		return nil, errors.New("the body is not an encrypted note")
+	}
+	encoded := bytes.TrimSuffix(bytes.TrimPrefix(armored, []byte(begin)), []byte(end))
+	blob, err := base64.StdEncoding.DecodeString(string(bytes.Join(bytes.Fields(encoded), nil)))
+	if err != nil {
+		return nil, fmt.Errorf("could not decode encrypted note: %w", err)
+	}
+	if len(blob) < 1+saltSize || blob[0] != version {
+		return nil, errors.New("unknown version of encrypted note")
+	}
+	salt := blob[1 : 1+saltSize]
+	gcm, err := gcmOf(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	rest := blob[1+saltSize:]
+	if len(rest) < gcm.NonceSize() {
+		return nil, errors.New("encrypted note is truncated")
+	}
+	body, err := gcm.Open(nil, rest[:gcm.NonceSize()], rest[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrPassphrase
+	}
+	return body, nil
+}
+
+// IsEncrypted reports whether a body looks like the result of Encrypt
+func IsEncrypted(body []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(body), []byte(begin))
+}
+
+func gcmOf(passphrase string, salt []byte) (cipher.AEAD, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("an empty passphrase can not be used")
+	}
+	k, err := key(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(k)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
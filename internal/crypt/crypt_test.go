@@ -0,0 +1,44 @@
+package crypt
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	for _, body := range []string{"", "a note with #tags", string(bytes.Repeat([]byte("long lines of text\n"), 100))} {
+		encrypted, err := Encrypt([]byte(body), "secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !IsEncrypted(encrypted) {
+			t.Fatalf("expected %q to look encrypted", encrypted)
+		}
+		if len(body) > 0 && bytes.Contains(encrypted, []byte(body)) {
+			t.Fatal("expected the body to not be in the clear")
+		}
+		decrypted, err := Decrypt(encrypted, "secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(decrypted) != body {
+			t.Fatalf("expected %q, got %q", body, decrypted)
+		}
+	}
+}
+
+func TestWrongPassphrase(t *testing.T) {
+	encrypted, err := Encrypt([]byte("a note"), "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Decrypt(encrypted, "wrong")
+	if !errors.Is(err, ErrPassphrase) {
+		t.Fatalf("expected ErrPassphrase, got %v", err)
+	}
+	_, err = Decrypt([]byte("a note in the clear"), "secret")
+	if err == nil {
+		t.Fatal("expected an error decrypting a note in the clear")
+	}
+}
@@ -0,0 +1,134 @@
+package diff
+
+import (
+	"unicode"
+)
+
+type Op int
+
+const (
+	Equal Op = iota
+	Delete
+	Insert
+)
+
+// Edit is a run of text that is equal in both, deleted from the first or inserted into the second text
+type Edit struct {
+	Op   Op
+	Text string
+}
+
+// Words splits text into words and the runs of whitespace between them, such that joining them gives back the text
+func Words(text string) []string {
+	var words []string
+	start := 0
+	runes := []rune(text)
+	for i := 1; i <= len(runes); i++ {
+		if i == len(runes) || unicode.IsSpace(runes[i]) != unicode.IsSpace(runes[i-1]) {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	return words
+}
+
+// WordDiff returns the edits turning a into b, word by word
+func WordDiff(a, b string) []Edit {
+	return Diff(Words(a), Words(b))
+}
+
+// maxTable is the size of the largest table of common subsequences Diff builds, i.e. of the words between the
+// common prefix and suffix of both texts. Above it, about 32MB, the words between are diffed as deleted and
+// inserted as a whole
+const maxTable = 1 << 22
+
+// Diff returns the edits turning a into b, by the longest common subsequence of them
+func Diff(a, b []string) []Edit {
+	// the common prefix and suffix are trimmed, since they often are most of a note
+	var prefix, suffix int
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var edits []Edit
+	add := func(op Op, text string) {
+		if len(edits) > 0 && edits[len(edits)-1].Op == op {
+			edits[len(edits)-1].Text += text
+			return
+		}
+		edits = append(edits, Edit{Op: op, Text: text})
+	}
+	for _, w := range a[:prefix] {
+		add(Equal, w)
+	}
+
+	x, y := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if (len(x)+1)*(len(y)+1) > maxTable {
+		for _, w := range x {
+			add(Delete, w)
+		}
+		for _, w := range y {
+			add(Insert, w)
+		}
+	} else {
+		lcsDiff(x, y, add)
+	}
+	for _, w := range a[len(a)-suffix:] {
+		add(Equal, w)
+	}
+	return edits
+}
+
+// lcsDiff adds the edits turning x into y, by a table of the longest common subsequences of them
+func lcsDiff(x, y []string, add func(op Op, text string)) {
+	// lcs[i][j] is the length of the longest common subsequence of x[i:] and y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			add(Equal, x[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			add(Delete, x[i])
+			i++
+		default:
+			add(Insert, y[j])
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		add(Delete, x[i])
+	}
+	for ; j < len(y); j++ {
+		add(Insert, y[j])
+	}
+}
+
+// Changed reports whether any of the edits is a deletion or an insertion
+func Changed(edits []Edit) bool {
+	for _, e := range edits {
+		if e.Op != Equal {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package diff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWords(t *testing.T) {
+	text := "Buy  milk\nand eggs "
+	words := Words(text)
+	expected := []string{"Buy", "  ", "milk", "\n", "and", " ", "eggs", " "}
+	if !reflect.DeepEqual(words, expected) {
+		t.Fatalf("expected %q, got %q", expected, words)
+	}
+	if strings.Join(words, "") != text {
+		t.Fatalf("expected the words to join to the text")
+	}
+	if len(Words("")) != 0 {
+		t.Fatalf("expected no words of an empty text")
+	}
+}
+
+func TestWordDiff(t *testing.T) {
+	for _, c := range []struct {
+		a, b     string
+		expected []Edit
+	}{
+		{"same text", "same text", []Edit{{Equal, "same text"}}},
+		{"buy milk and eggs", "buy oat milk and bread", []Edit{
+			{Equal, "buy "},
+			{Insert, "oat "},
+			{Equal, "milk and "},
+			{Delete, "eggs"},
+			{Insert, "bread"},
+		}},
+		{"", "new", []Edit{{Insert, "new"}}},
+		{"old", "", []Edit{{Delete, "old"}}},
+	} {
+		edits := WordDiff(c.a, c.b)
+		if !reflect.DeepEqual(edits, c.expected) {
+			t.Fatalf("%q -> %q: expected %v, got %v", c.a, c.b, c.expected, edits)
+		}
+
+		var a, b string
+		for _, e := range edits {
+			if e.Op != Insert {
+				a += e.Text
+			}
+			if e.Op != Delete {
+				b += e.Text
+			}
+		}
+		if a != c.a || b != c.b {
+			t.Fatalf("%q -> %q: expected the edits to give back both texts, got %q and %q", c.a, c.b, a, b)
+		}
+		if Changed(edits) != (c.a != c.b) {
+			t.Fatalf("%q -> %q: expected changed to be %v", c.a, c.b, c.a != c.b)
+		}
+	}
+}
+
+func TestDiffLarge(t *testing.T) {
+	var a, b []string
+	for i := 0; i < 3000; i++ {
+		a = append(a, "a", " ")
+		b = append(b, "b", " ")
+	}
+	a = append([]string{"same", " "}, a...)
+	b = append([]string{"same", " "}, b...)
+	edits := Diff(a, b)
+	expected := []Edit{
+		{Equal, "same "},
+		{Delete, strings.Join(a[2:len(a)-1], "")},
+		{Insert, strings.Join(b[2:len(b)-1], "")},
+		{Equal, " "},
+	}
+	if !reflect.DeepEqual(edits, expected) {
+		t.Fatalf("expected the changed words to be replaced as a whole, got %d edits", len(edits))
+	}
+}
@@ -1,49 +1,182 @@
 package fss
 
 import (
+	"errors"
 	"fmt"
 	"github.com/crholm/mark"
-	"io/ioutil"
-	"log"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
-func SaveNote(meta mark.Header, content []byte) (string, error) {
-	filename := GetFullPath(meta)
-	_ = os.MkdirAll(GetPath(meta), 0755)
-	err := ioutil.WriteFile(filename, content, 0644)
-	return filename, err
+// maxCollisions is how many names, a millisecond apart, SaveNote tries before giving up
+const maxCollisions = 1000
+
+// SaveNote puts a new note in the store, named by its time of creation, and returns the name and the header of it.
+// The body is stored as is, i.e. encrypted if the note is. Existing notes are never overwritten, if a note of the same
+// millisecond exists the note is named by, and created at, the next free millisecond
+func SaveNote(s Store, meta mark.Header, body []byte) (string, mark.Header, error) {
+	for i := 0; i < maxCollisions; i++ {
+		data, err := mark.MarshalNote(meta, body)
+		if err != nil {
+			return "", meta, err
+		}
+		name := GetName(meta)
+		err = s.Create(name, data)
+		if !errors.Is(err, fs.ErrExist) {
+			return name, meta, err
+		}
+		meta.CreatedAt = meta.CreatedAt.Add(time.Millisecond)
+	}
+	return "", meta, fmt.Errorf("could not find a free name for the note, tried %d names from %s", maxCollisions, GetName(meta))
+}
+
+// Filename schemes, i.e. how the time of creation is written in the filename of notes. Colons, as in
+// 2022-08-12_14:04:49.123Z_Friday.md, can not be used on windows and by some sync tools, which the portable
+// scheme, as in 2022-08-12_14-04-49.123Z_Friday.md, can
+const (
+	FilenamesColons   = "colons"
+	FilenamesPortable = "portable"
+)
+
+var filenameLayouts = map[string]string{
+	FilenamesColons:   "2006-01-02_15:04:05.000Z0700_Monday",
+	FilenamesPortable: "2006-01-02_15-04-05.000Z0700_Monday",
 }
 
+var filenameScheme = FilenamesColons
+
+func FilenameSchemes() []string {
+	return []string{FilenamesColons, FilenamesPortable}
+}
+
+// SetFilenameScheme sets the scheme of the filenames of new notes, FilenamesColons unless set
+func SetFilenameScheme(scheme string) error {
+	if len(scheme) == 0 {
+		scheme = FilenamesColons
+	}
+	if _, ok := filenameLayouts[scheme]; !ok {
+		return fmt.Errorf("unknown filename scheme %s, expected %s or %s", scheme, FilenamesColons, FilenamesPortable)
+	}
+	filenameScheme = scheme
+	return nil
+}
+
+// GetFilename returns the filename of a note, i.e. its time of creation in UTC with millisecond precision,
+// e.g. 2022-08-12_14:04:49.123Z_Friday.md, in the scheme set by SetFilenameScheme
 func GetFilename(meta mark.Header) string {
-	return fmt.Sprintf("%s.md", meta.CreatedAt.In(time.UTC).Format("2006-01-02_15:04:05Z0700_Monday"))
+	return fmt.Sprintf("%s.md", meta.CreatedAt.In(time.UTC).Format(filenameLayouts[filenameScheme]))
 }
-func GetLibPath() string {
-	return filepath.Join(GetStoragePath(), "lib")
+
+// GetName returns the name of a note in a Store, i.e. the filename within a dir of the year and month, e.g.
+// 2022/08/2022-08-12_14:04:49.123Z_Friday.md. Both the dirs and the filename are of the time in UTC, regardless of
+// the location of CreatedAt, so that the name can be derived from the filename alone, see GetFilenameToName
+func GetName(meta mark.Header) string {
+	created := meta.CreatedAt.In(time.UTC)
+	return path.Join(created.Format("2006"), created.Format("01"), GetFilename(meta))
 }
-func GetPath(meta mark.Header) string {
-	return filepath.Join(GetLibPath(), meta.CreatedAt.Format("2006"), meta.CreatedAt.Format("01"))
+
+func GetTemplatePath(name string) (string, error) {
+	path, err := GetStoragePath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(path, "templates", name+".md"), nil
 }
-func GetFullPath(meta mark.Header) string {
-	return filepath.Join(GetPath(meta), GetFilename(meta))
+
+var storagePath string
+
+// SetStoragePath sets the dir where notes, indexes and config of a notebook is stored, overriding DefaultStoragePath
+func SetStoragePath(path string) {
+	storagePath = path
 }
 
-func GetStoragePath() string {
+// DefaultStoragePath returns $MARK_HOME, or ~/.mark if it is not set
+func DefaultStoragePath() (string, error) {
+	if path := os.Getenv("MARK_HOME"); len(path) > 0 {
+		return filepath.Abs(path)
+	}
 	dirname, err := os.UserHomeDir()
 	if err != nil {
-		log.Fatal(err)
+		return "", fmt.Errorf("could not find the home dir, set MARK_HOME to where notes are stored: %w", err)
+	}
+	return filepath.Join(dirname, ".mark"), nil
+}
+
+// GetStoragePath returns the path set by SetStoragePath, or the default one, which is an error if there is no home
+// dir and $MARK_HOME is not set
+func GetStoragePath() (string, error) {
+	path := storagePath
+	if len(path) == 0 {
+		var err error
+		path, err = DefaultStoragePath()
+		if err != nil {
+			return "", err
+		}
 	}
-	path := filepath.Join(dirname, ".mark")
 	_ = os.MkdirAll(path, 0755)
-	return path
+	return path, nil
 }
 
-func GetFilenameToPath(filename string) (string, error) {
-	timestamp, err := time.Parse("2006-01-02_15:04:05Z0700_Monday.md", filename)
+// GetFilenameTimestamp parses the time of creation from a filename, either of whole seconds, as notes were named
+// before, e.g. 2022-08-12_14:04:49Z_Friday.md, or with milliseconds, e.g. 2022-08-12_14:04:49.123Z_Friday.md, in
+// either filename scheme
+func GetFilenameTimestamp(filename string) (time.Time, error) {
+	// time.Parse accepts fractional seconds following the seconds of the layout, which covers both
+	t, err := time.Parse("2006-01-02_15:04:05Z0700_Monday.md", filename)
+	if err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02_15-04-05Z0700_Monday.md", filename)
+}
+
+// GetFilenameInScheme returns the filename in another filename scheme, keeping the precision of the time in it
+func GetFilenameInScheme(filename string, scheme string) (string, error) {
+	_, err := GetFilenameTimestamp(filename)
 	if err != nil {
 		return "", err
 	}
-	return GetFullPath(mark.Header{CreatedAt: timestamp}), nil
+	date, rest, _ := strings.Cut(filename, "_")
+	clock := rest[:len("15:04:05")]
+	switch scheme {
+	case FilenamesColons:
+		clock = strings.ReplaceAll(clock, "-", ":")
+	case FilenamesPortable:
+		clock = strings.ReplaceAll(clock, ":", "-")
+	default:
+		return "", fmt.Errorf("unknown filename scheme %s, expected %s or %s", scheme, FilenamesColons, FilenamesPortable)
+	}
+	return date + "_" + clock + rest[len(clock):], nil
+}
+
+// GetFilenameToName returns the name of a note in a Store from its filename, e.g. 2022-08-12_14:04:49.123Z_Friday.md
+func GetFilenameToName(filename string) (string, error) {
+	timestamp, err := GetFilenameTimestamp(filename)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(path.Dir(GetName(mark.Header{CreatedAt: timestamp})), filename), nil
+}
+
+// GetFilenameToNames returns the names a note may have in a Store given its filename in any filename scheme,
+// starting with the one of the filename
+func GetFilenameToNames(filename string) ([]string, error) {
+	name, err := GetFilenameToName(filename)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{name}
+	for _, scheme := range FilenameSchemes() {
+		other, err := GetFilenameInScheme(filename, scheme)
+		if err != nil {
+			return nil, err
+		}
+		if other != filename {
+			names = append(names, path.Join(path.Dir(name), other))
+		}
+	}
+	return names, nil
 }
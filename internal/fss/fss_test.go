@@ -0,0 +1,178 @@
+package fss
+
+import (
+	"github.com/crholm/mark"
+	"path"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSaveNoteCollision(t *testing.T) {
+	s := NewMemory()
+	created := time.Date(2022, 8, 12, 14, 4, 49, 123456789, time.UTC)
+
+	expected := []string{
+		"2022/08/2022-08-12_14:04:49.123Z_Friday.md",
+		"2022/08/2022-08-12_14:04:49.124Z_Friday.md",
+		"2022/08/2022-08-12_14:04:49.125Z_Friday.md",
+	}
+	for i, e := range expected {
+		name, header, err := SaveNote(s, mark.Header{CreatedAt: created}, []byte{byte('a' + i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name != e || GetName(header) != e {
+			t.Fatalf("expected note %d to be named %s, got %s created at %v", i, e, name, header.CreatedAt)
+		}
+	}
+	for i, name := range expected {
+		data, err := s.Get(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		header, body, err := mark.UnmarshalNote(data)
+		if err != nil || string(body) != string([]byte{byte('a' + i)}) {
+			t.Fatalf("expected %s to not be overwritten, got %q, %v", name, body, err)
+		}
+		// the time of creation is stored as named by, i.e. moved a millisecond forward for each collision
+		if GetName(header) != name {
+			t.Fatalf("expected %s to be created at the time of its name, got %v", name, header.CreatedAt)
+		}
+	}
+}
+
+func TestGetFilenameToName(t *testing.T) {
+	for filename, expected := range map[string]string{
+		"2022-08-12_14:04:49Z_Friday.md":     "2022/08/2022-08-12_14:04:49Z_Friday.md",
+		"2022-08-12_14:04:49.123Z_Friday.md": "2022/08/2022-08-12_14:04:49.123Z_Friday.md",
+	} {
+		name, err := GetFilenameToName(filename)
+		if err != nil {
+			t.Fatalf("%s: %v", filename, err)
+		}
+		if name != expected {
+			t.Fatalf("%s: expected %s, got %s", filename, expected, name)
+		}
+	}
+	_, err := GetFilenameToName("notes.md")
+	if err == nil {
+		t.Fatal("expected an error for a filename that is not a timestamp")
+	}
+}
+
+func TestGetNameAcrossTimezones(t *testing.T) {
+	stockholm, err := time.LoadLocation("Europe/Stockholm")
+	if err != nil {
+		t.Skip("no tz database:", err)
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no tz database:", err)
+	}
+
+	for _, c := range []struct {
+		created  time.Time
+		expected string
+	}{
+		// the 1st of a month locally, but still the previous month in UTC
+		{time.Date(2022, 9, 1, 0, 30, 0, 0, stockholm), "2022/08/2022-08-31_22:30:00.000Z_Wednesday.md"},
+		// new year locally, but still the previous year in UTC
+		{time.Date(2022, 1, 1, 0, 30, 0, 0, stockholm), "2021/12/2021-12-31_23:30:00.000Z_Friday.md"},
+		// the last of a month locally, but the next month in UTC
+		{time.Date(2022, 8, 31, 22, 30, 0, 0, newYork), "2022/09/2022-09-01_02:30:00.000Z_Thursday.md"},
+		// new years eve locally, but the next year in UTC
+		{time.Date(2021, 12, 31, 23, 30, 0, 0, newYork), "2022/01/2022-01-01_04:30:00.000Z_Saturday.md"},
+		{time.Date(2022, 8, 12, 14, 4, 49, 0, time.UTC), "2022/08/2022-08-12_14:04:49.000Z_Friday.md"},
+	} {
+		name := GetName(mark.Header{CreatedAt: c.created})
+		if name != c.expected {
+			t.Fatalf("%v: expected %s, got %s", c.created, c.expected, name)
+		}
+		fromFilename, err := GetFilenameToName(path.Base(name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fromFilename != name {
+			t.Fatalf("%v: expected the name from the filename to be %s, got %s", c.created, name, fromFilename)
+		}
+	}
+}
+
+func TestFilenameSchemes(t *testing.T) {
+	defer SetFilenameScheme(FilenamesColons)
+	created := time.Date(2022, 8, 12, 14, 4, 49, 123000000, time.UTC)
+
+	err := SetFilenameScheme(FilenamesPortable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := GetName(mark.Header{CreatedAt: created})
+	if name != "2022/08/2022-08-12_14-04-49.123Z_Friday.md" {
+		t.Fatalf("expected a name without colons, got %s", name)
+	}
+	if SetFilenameScheme("dots") == nil {
+		t.Fatal("expected an error for an unknown scheme")
+	}
+
+	for _, c := range []struct {
+		filename string
+		scheme   string
+		expected string
+	}{
+		{"2022-08-12_14:04:49.123Z_Friday.md", FilenamesPortable, "2022-08-12_14-04-49.123Z_Friday.md"},
+		{"2022-08-12_14:04:49Z_Friday.md", FilenamesPortable, "2022-08-12_14-04-49Z_Friday.md"},
+		{"2022-08-12_14-04-49Z_Friday.md", FilenamesColons, "2022-08-12_14:04:49Z_Friday.md"},
+		{"2022-08-12_14-04-49+0200_Friday.md", FilenamesColons, "2022-08-12_14:04:49+0200_Friday.md"},
+		{"2022-08-12_14:04:49Z_Friday.md", FilenamesColons, "2022-08-12_14:04:49Z_Friday.md"},
+	} {
+		res, err := GetFilenameInScheme(c.filename, c.scheme)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res != c.expected {
+			t.Fatalf("%s in %s: expected %s, got %s", c.filename, c.scheme, c.expected, res)
+		}
+		t1, err := GetFilenameTimestamp(c.filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t2, err := GetFilenameTimestamp(res)
+		if err != nil || !t1.Equal(t2) {
+			t.Fatalf("expected %s and %s to be the same time, got %v, %v, %v", c.filename, res, t1, t2, err)
+		}
+	}
+
+	names, err := GetFilenameToNames("2022-08-12_14-04-49.123Z_Friday.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"2022/08/2022-08-12_14-04-49.123Z_Friday.md", "2022/08/2022-08-12_14:04:49.123Z_Friday.md"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected %v, got %v", expected, names)
+	}
+}
+
+func TestGetStoragePath(t *testing.T) {
+	defer SetStoragePath("")
+	SetStoragePath("")
+	t.Setenv("HOME", "")
+	t.Setenv("MARK_HOME", "")
+	_, err := GetStoragePath()
+	if err == nil {
+		t.Fatal("expected an error without a home dir or $MARK_HOME")
+	}
+
+	home := t.TempDir()
+	t.Setenv("MARK_HOME", home)
+	dir, err := GetStoragePath()
+	if err != nil || dir != home {
+		t.Fatalf("expected $MARK_HOME, got %s, %v", dir, err)
+	}
+	SetStoragePath(filepath.Join(home, "work"))
+	dir, err = GetStoragePath()
+	if err != nil || dir != filepath.Join(home, "work") {
+		t.Fatalf("expected the path set, got %s, %v", dir, err)
+	}
+}
@@ -0,0 +1,391 @@
+package fss
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Store is where the notes and indexes of a notebook are kept. Notes are named by their slash separated path
+// relative to the lib dir, e.g. 2022/08/2022-08-12_14:04:49Z_Friday.md, and indexes by their name, e.g. index.json.
+// Get and ReadIndex returns an error wrapping fs.ErrNotExist for what is not in the store
+type Store interface {
+	Get(name string) ([]byte, error)
+	Put(name string, data []byte) error
+	// Create puts a note that does not exist, or returns an error wrapping fs.ErrExist
+	Create(name string, data []byte) error
+	Delete(name string) error
+	// List returns the names of all notes, sorted
+	List() ([]string, error)
+	// Walk calls fn for each note, in the order of List
+	Walk(fn func(name string, data []byte) error) error
+	ReadIndex(name string) ([]byte, error)
+	WriteIndex(name string, data []byte) error
+
+	// Assets are files attached to notes, named by the hash of their content, see AssetName
+	GetAsset(name string) ([]byte, error)
+	PutAsset(name string, data []byte) error
+	DeleteAsset(name string) error
+	ListAssets() ([]string, error)
+}
+
+// ValidName reports whether name is the name of a note within the lib dir
+func ValidName(name string) bool {
+	return path.Clean(name) == name && !path.IsAbs(name) && !strings.HasPrefix(name, "../") && path.Ext(name) == ".md"
+}
+
+func invalidName(name string) error {
+	return fmt.Errorf("invalid note name %s, must be a relative path of a .md file", name)
+}
+
+// AssetName returns the content addressed name of an asset, i.e. the sha256 of data along with the extension of
+// the file it came from, e.g. 9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08.png
+func AssetName(filename string, data []byte) string {
+	return fmt.Sprintf("%x%s", sha256.Sum256(data), strings.ToLower(filepath.Ext(filename)))
+}
+
+// AssetLink returns the link to an asset from the markdown of a note, relative to the year/month dir of the note
+func AssetLink(name string) string {
+	return "../../../assets/" + name
+}
+
+// ValidAssetName reports whether name is the name of a file directly within the assets dir
+func ValidAssetName(name string) bool {
+	return len(name) > 0 && !strings.ContainsAny(name, `/\`) && !strings.HasPrefix(name, ".")
+}
+
+func invalidAssetName(name string) error {
+	return fmt.Errorf("invalid asset name %s", name)
+}
+
+// FS stores notes in the lib dir of a storage dir, e.g. ~/.mark/lib, and indexes in the storage dir itself
+type FS struct {
+	Root string
+}
+
+func NewFS(root string) *FS {
+	return &FS{Root: root}
+}
+
+// Path returns the path on disk of a note
+func (s *FS) Path(name string) string {
+	return filepath.Join(s.Root, "lib", filepath.FromSlash(name))
+}
+
+func (s *FS) Get(name string) ([]byte, error) {
+	if !ValidName(name) {
+		return nil, invalidName(name)
+	}
+	return ioutil.ReadFile(s.Path(name))
+}
+
+func (s *FS) Put(name string, data []byte) error {
+	if !ValidName(name) {
+		return invalidName(name)
+	}
+	err := os.MkdirAll(filepath.Dir(s.Path(name)), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.Path(name), data, 0644)
+}
+
+func (s *FS) Create(name string, data []byte) error {
+	if !ValidName(name) {
+		return invalidName(name)
+	}
+	err := os.MkdirAll(filepath.Dir(s.Path(name)), 0755)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.Path(name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s *FS) Delete(name string) error {
+	if !ValidName(name) {
+		return invalidName(name)
+	}
+	return os.Remove(s.Path(name))
+}
+
+func (s *FS) List() ([]string, error) {
+	lib := filepath.Join(s.Root, "lib")
+	var names []string
+	err := filepath.WalkDir(lib, func(p string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && p == lib {
+			return filepath.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(p) != ".md" {
+			return nil
+		}
+		rel, err := filepath.Rel(lib, p)
+		if err != nil {
+			return err
+		}
+		names = append(names, filepath.ToSlash(rel))
+		return nil
+	})
+	sort.Strings(names)
+	return names, err
+}
+
+func (s *FS) Walk(fn func(name string, data []byte) error) error {
+	return walk(s, fn)
+}
+
+func (s *FS) ReadIndex(name string) ([]byte, error) {
+	return ioutil.ReadFile(filepath.Join(s.Root, name))
+}
+
+// WriteIndex writes the index to a temporary file which replaces the index, so that it is never read half written
+func (s *FS) WriteIndex(name string, data []byte) error {
+	err := os.MkdirAll(s.Root, 0755)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(s.Root, "."+name+".*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Chmod(0644)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), filepath.Join(s.Root, name))
+}
+
+// AssetPath returns the path on disk of an asset
+func (s *FS) AssetPath(name string) string {
+	return filepath.Join(s.Root, "assets", name)
+}
+
+func (s *FS) GetAsset(name string) ([]byte, error) {
+	if !ValidAssetName(name) {
+		return nil, invalidAssetName(name)
+	}
+	return ioutil.ReadFile(s.AssetPath(name))
+}
+
+func (s *FS) PutAsset(name string, data []byte) error {
+	if !ValidAssetName(name) {
+		return invalidAssetName(name)
+	}
+	err := os.MkdirAll(filepath.Join(s.Root, "assets"), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.AssetPath(name), data, 0644)
+}
+
+func (s *FS) DeleteAsset(name string) error {
+	if !ValidAssetName(name) {
+		return invalidAssetName(name)
+	}
+	return os.Remove(s.AssetPath(name))
+}
+
+func (s *FS) ListAssets() ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(s.Root, "assets"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && ValidAssetName(e.Name()) {
+			names = append(names, e.Name())
+		}
+	}
+	return names, nil
+}
+
+// Memory keeps notes and indexes in memory, e.g. for tests
+type Memory struct {
+	mu      sync.Mutex
+	notes   map[string][]byte
+	indexes map[string][]byte
+	assets  map[string][]byte
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		notes:   map[string][]byte{},
+		indexes: map[string][]byte{},
+		assets:  map[string][]byte{},
+	}
+}
+
+func (s *Memory) Get(name string) ([]byte, error) {
+	if !ValidName(name) {
+		return nil, invalidName(name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.notes[name]
+	if !ok {
+		return nil, fmt.Errorf("note %s: %w", name, fs.ErrNotExist)
+	}
+	return append([]byte(nil), data...), nil
+}
+
+func (s *Memory) Put(name string, data []byte) error {
+	if !ValidName(name) {
+		return invalidName(name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.notes[name] = append([]byte(nil), data...)
+	return nil
+}
+
+func (s *Memory) Create(name string, data []byte) error {
+	if !ValidName(name) {
+		return invalidName(name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.notes[name]; ok {
+		return fmt.Errorf("note %s: %w", name, fs.ErrExist)
+	}
+	s.notes[name] = append([]byte(nil), data...)
+	return nil
+}
+
+func (s *Memory) Delete(name string) error {
+	if !ValidName(name) {
+		return invalidName(name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.notes[name]; !ok {
+		return fmt.Errorf("note %s: %w", name, fs.ErrNotExist)
+	}
+	delete(s.notes, name)
+	return nil
+}
+
+func (s *Memory) List() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var names []string
+	for name := range s.notes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *Memory) Walk(fn func(name string, data []byte) error) error {
+	return walk(s, fn)
+}
+
+func (s *Memory) ReadIndex(name string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.indexes[name]
+	if !ok {
+		return nil, fmt.Errorf("index %s: %w", name, fs.ErrNotExist)
+	}
+	return append([]byte(nil), data...), nil
+}
+
+func (s *Memory) WriteIndex(name string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.indexes[name] = append([]byte(nil), data...)
+	return nil
+}
+
+func (s *Memory) GetAsset(name string) ([]byte, error) {
+	if !ValidAssetName(name) {
+		return nil, invalidAssetName(name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.assets[name]
+	if !ok {
+		return nil, fmt.Errorf("asset %s: %w", name, fs.ErrNotExist)
+	}
+	return append([]byte(nil), data...), nil
+}
+
+func (s *Memory) PutAsset(name string, data []byte) error {
+	if !ValidAssetName(name) {
+		return invalidAssetName(name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.assets[name] = append([]byte(nil), data...)
+	return nil
+}
+
+func (s *Memory) DeleteAsset(name string) error {
+	if !ValidAssetName(name) {
+		return invalidAssetName(name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.assets[name]; !ok {
+		return fmt.Errorf("asset %s: %w", name, fs.ErrNotExist)
+	}
+	delete(s.assets, name)
+	return nil
+}
+
+func (s *Memory) ListAssets() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var names []string
+	for name := range s.assets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func walk(s Store, fn func(name string, data []byte) error) error {
+	names, err := s.List()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		data, err := s.Get(name)
+		if err != nil {
+			return err
+		}
+		err = fn(name, data)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
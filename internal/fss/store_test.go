@@ -0,0 +1,147 @@
+package fss
+
+import (
+	"errors"
+	"io/fs"
+	"reflect"
+	"testing"
+)
+
+func TestStores(t *testing.T) {
+	stores := map[string]Store{
+		"fs":     NewFS(t.TempDir()),
+		"memory": NewMemory(),
+	}
+	for kind, s := range stores {
+		t.Run(kind, func(t *testing.T) {
+			testStore(t, s)
+		})
+	}
+}
+
+func testStore(t *testing.T, s Store) {
+	names, err := s.List()
+	if err != nil || len(names) != 0 {
+		t.Fatalf("expected an empty store, got %v, %v", names, err)
+	}
+	_, err = s.Get("2022/08/2022-08-12_14:04:49Z_Friday.md")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected a missing note to not exist, got %v", err)
+	}
+	_, err = s.ReadIndex("index.json")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected a missing index to not exist, got %v", err)
+	}
+
+	notes := map[string]string{
+		"2022/08/2022-08-12_14:04:49Z_Friday.md":   "a",
+		"2022/08/2022-08-13_14:04:49Z_Saturday.md": "b",
+		"2021/01/2021-01-01_00:00:00Z_Friday.md":   "c",
+	}
+	for name, data := range notes {
+		err = s.Put(name, []byte(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = s.Create("2022/08/2022-08-12_14:04:49Z_Friday.md", []byte("x"))
+	if !errors.Is(err, fs.ErrExist) {
+		t.Fatalf("expected creating an existing note to fail, got %v", err)
+	}
+	for _, name := range []string{"../outside.md", "/2022/08/2022-08-12_14:04:49Z_Friday.md", "2022/../../outside.md", "notes.txt"} {
+		if s.Put(name, []byte("x")) == nil {
+			t.Fatalf("expected an error putting the invalid note %s", name)
+		}
+		if s.Create(name, []byte("x")) == nil {
+			t.Fatalf("expected an error creating the invalid note %s", name)
+		}
+		if _, err := s.Get(name); err == nil || errors.Is(err, fs.ErrNotExist) {
+			t.Fatalf("expected an error getting the invalid note %s, got %v", name, err)
+		}
+		if err := s.Delete(name); err == nil || errors.Is(err, fs.ErrNotExist) {
+			t.Fatalf("expected an error deleting the invalid note %s, got %v", name, err)
+		}
+	}
+
+	names, err = s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"2021/01/2021-01-01_00:00:00Z_Friday.md",
+		"2022/08/2022-08-12_14:04:49Z_Friday.md",
+		"2022/08/2022-08-13_14:04:49Z_Saturday.md",
+	}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected %v, got %v", expected, names)
+	}
+
+	var walked []string
+	err = s.Walk(func(name string, data []byte) error {
+		if string(data) != notes[name] {
+			t.Fatalf("expected %s to be %q, got %q", name, notes[name], data)
+		}
+		walked = append(walked, name)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(walked, expected) {
+		t.Fatalf("expected to walk %v, got %v", expected, walked)
+	}
+
+	err = s.Delete("2022/08/2022-08-12_14:04:49Z_Friday.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Get("2022/08/2022-08-12_14:04:49Z_Friday.md")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected a deleted note to not exist, got %v", err)
+	}
+
+	asset := AssetName("Photo.PNG", []byte("png"))
+	if asset != "8f8cbb7dcf46e0bc7d53265749a6c17d116093a6ba95e442764060c76fd4a86c.png" {
+		t.Fatalf("expected a sha256 named asset, got %s", asset)
+	}
+	err = s.PutAsset(asset, []byte("png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"../escape.png", "sub/escape.png", ".hidden.png", ""} {
+		if s.PutAsset(name, []byte("png")) == nil {
+			t.Fatalf("expected an error putting the invalid asset %q", name)
+		}
+		if _, err := s.GetAsset(name); err == nil || errors.Is(err, fs.ErrNotExist) {
+			t.Fatalf("expected an error getting the invalid asset %q, got %v", name, err)
+		}
+		if err := s.DeleteAsset(name); err == nil || errors.Is(err, fs.ErrNotExist) {
+			t.Fatalf("expected an error deleting the invalid asset %q, got %v", name, err)
+		}
+	}
+	assets, err := s.ListAssets()
+	if err != nil || !reflect.DeepEqual(assets, []string{asset}) {
+		t.Fatalf("expected assets %v, got %v, %v", []string{asset}, assets, err)
+	}
+	data, err := s.GetAsset(asset)
+	if err != nil || string(data) != "png" {
+		t.Fatalf("expected the asset to be read back, got %q, %v", data, err)
+	}
+	err = s.DeleteAsset(asset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.GetAsset(asset)
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected a deleted asset to not exist, got %v", err)
+	}
+
+	err = s.WriteIndex("index.json", []byte("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err = s.ReadIndex("index.json")
+	if err != nil || string(data) != "{}" {
+		t.Fatalf("expected the index to be read back, got %q, %v", data, err)
+	}
+}
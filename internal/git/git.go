@@ -0,0 +1,351 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotInstalled is returned when the git executable can not be found
+var ErrNotInstalled = errors.New("git is not installed, or not in $PATH")
+
+// Error is a failed git command along with what git complained about
+type Error struct {
+	Args   []string
+	Stderr string
+	Err    error
+}
+
+func (e *Error) Error() string {
+	msg := e.Stderr
+	if len(msg) == 0 {
+		msg = e.Err.Error()
+	}
+	return fmt.Sprintf("git %s: %s", strings.Join(e.Args, " "), msg)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Runner runs git in the working tree of a repo, e.g. the storage dir of a notebook
+type Runner struct {
+	Dir string
+}
+
+func New(dir string) Runner {
+	return Runner{Dir: dir}
+}
+
+// Run runs git with args and returns what it outputs on stdout
+func (r Runner) Run(args ...string) ([]byte, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, ErrNotInstalled
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.Dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return out, &Error{Args: args, Stderr: strings.TrimSpace(stderr.String()), Err: err}
+	}
+	return out, nil
+}
+
+// IsRepo reports whether the dir is within the working tree of a repo
+func (r Runner) IsRepo() bool {
+	out, err := r.Run("rev-parse", "--is-inside-work-tree")
+	return err == nil && strings.TrimSpace(string(out)) == "true"
+}
+
+type Status byte
+
+const (
+	Added    Status = 'A'
+	Modified Status = 'M'
+	Deleted  Status = 'D'
+	Renamed  Status = 'R'
+)
+
+// Change is a changed file of the working tree, relative to the dir of the runner
+type Change struct {
+	Status Status
+	Path   string
+	// From is the path a renamed file was renamed from
+	From string
+}
+
+// Status returns the changes within the dir of the working tree and the index since HEAD, untracked files are Added
+func (r Runner) Status() ([]Change, error) {
+	out, err := r.Run("status", "--porcelain", "-z", "--untracked-files=all", "--", ".")
+	if err != nil {
+		return nil, err
+	}
+	// the paths are relative to the root of the repo, which the dir may be a sub dir of
+	prefix, err := r.Run("rev-parse", "--show-prefix")
+	if err != nil {
+		return nil, err
+	}
+	return parseStatus(out, strings.TrimSpace(string(prefix))), nil
+}
+
+func parseStatus(out []byte, prefix string) []Change {
+	var changes []Change
+	entries := strings.Split(string(out), "\x00")
+	for i := 0; i < len(entries); i++ {
+		e := entries[i]
+		if len(e) < 4 {
+			continue
+		}
+		x, y, p := e[0], e[1], e[3:]
+		code := x
+		if code == ' ' {
+			code = y
+		}
+		c := Change{Path: strings.TrimPrefix(p, prefix)}
+		switch code {
+		case '?', 'A':
+			c.Status = Added
+		case 'D':
+			c.Status = Deleted
+		case 'R', 'C':
+			c.Status = Renamed
+			// the path renamed from is the following entry
+			if i+1 < len(entries) {
+				c.From = strings.TrimPrefix(entries[i+1], prefix)
+				i++
+			}
+		default:
+			c.Status = Modified
+		}
+		changes = append(changes, c)
+	}
+	return changes
+}
+
+// AddAll stages all changes of the working tree, including removed files
+func (r Runner) AddAll() error {
+	_, err := r.Run("add", "--all", ".")
+	return err
+}
+
+// Add stages files, including removed ones, the paths are relative to the dir of the runner
+func (r Runner) Add(paths ...string) error {
+	_, err := r.Run(append([]string{"add", "--all", "--"}, relative(paths)...)...)
+	return err
+}
+
+// Untrack removes files from the repo, keeping them in the working tree, e.g. files that are to be ignored
+func (r Runner) Untrack(paths ...string) error {
+	_, err := r.Run(append([]string{"rm", "--quiet", "--cached", "--ignore-unmatch", "--"}, relative(paths)...)...)
+	return err
+}
+
+func relative(paths []string) []string {
+	var res []string
+	for _, p := range paths {
+		res = append(res, "./"+p)
+	}
+	return res
+}
+
+func (r Runner) Commit(message string) error {
+	_, err := r.Run("commit", "--quiet", "-m", message)
+	return err
+}
+
+// Amend replaces the last commit with one of the staged changes along with it, keeping the author and date
+func (r Runner) Amend(message string) error {
+	_, err := r.Run("commit", "--quiet", "--amend", "-m", message)
+	return err
+}
+
+// Upstream returns the remote branch the current branch tracks, e.g. origin/main
+func (r Runner) Upstream() (string, error) {
+	out, err := r.Run("rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{upstream}")
+	return strings.TrimSpace(string(out)), err
+}
+
+// Branch returns the name of the current branch, which may not have any commits yet
+func (r Runner) Branch() (string, error) {
+	out, err := r.Run("symbolic-ref", "--short", "HEAD")
+	return strings.TrimSpace(string(out)), err
+}
+
+// HasRemoteBranch reports whether the branch exists on the remote
+func (r Runner) HasRemoteBranch(remote string, branch string) bool {
+	_, err := r.Run("ls-remote", "--exit-code", "--heads", remote, branch)
+	return err == nil
+}
+
+// Pull merges the changes of the remote, or of the upstream of the current branch if remote is empty
+func (r Runner) Pull(remote string, branch string) error {
+	_, err := r.Run(append([]string{"pull", "--quiet", "--no-rebase", "--no-edit"}, refspec(remote, branch)...)...)
+	return err
+}
+
+// Push pushes to the remote, or to the upstream of the current branch if remote is empty
+func (r Runner) Push(remote string, branch string) error {
+	_, err := r.Run(append([]string{"push", "--quiet"}, refspec(remote, branch)...)...)
+	return err
+}
+
+func refspec(remote string, branch string) []string {
+	if len(remote) == 0 {
+		return nil
+	}
+	if len(branch) == 0 {
+		return []string{remote}
+	}
+	return []string{remote, branch}
+}
+
+type Commit struct {
+	Hash    string
+	Date    time.Time
+	Author  string
+	Subject string
+	// Body is the message following the subject, only read by Head
+	Body string
+}
+
+// Head returns the last commit of the current branch
+func (r Runner) Head() (Commit, error) {
+	out, err := r.Run("log", "-1", "--format=%h%x00%aI%x00%an%x00%s%x00%b")
+	if err != nil {
+		return Commit{}, err
+	}
+	parts := strings.SplitN(string(out), "\x00", 5)
+	if len(parts) != 5 {
+		return Commit{}, fmt.Errorf("could not read the last commit of %s", r.Dir)
+	}
+	date, err := time.Parse(time.RFC3339, parts[1])
+	if err != nil {
+		return Commit{}, fmt.Errorf("could not parse the date of commit %s: %w", parts[0], err)
+	}
+	return Commit{Hash: parts[0], Date: date, Author: parts[2], Subject: parts[3], Body: strings.TrimSpace(parts[4])}, nil
+}
+
+// Rev returns the full hash of a revision, e.g. HEAD
+func (r Runner) Rev(rev string) (string, error) {
+	out, err := r.Run("rev-parse", "--verify", "--quiet", rev)
+	return strings.TrimSpace(string(out)), err
+}
+
+// Pushed reports whether a revision is on any branch of a remote, as last fetched or pushed
+func (r Runner) Pushed(rev string) bool {
+	out, err := r.Run("branch", "--remotes", "--contains", rev)
+	return err == nil && len(bytes.TrimSpace(out)) > 0
+}
+
+// GitDir returns the absolute path of the .git dir of the repo
+func (r Runner) GitDir() (string, error) {
+	out, err := r.Run("rev-parse", "--absolute-git-dir")
+	return strings.TrimSpace(string(out)), err
+}
+
+// Log returns the commits that changed a file, following it across renames. The path is relative to the dir
+func (r Runner) Log(path string) ([]Commit, error) {
+	out, err := r.Run("log", "--follow", "--format=%h%x00%aI%x00%an%x00%s", "--", "./"+path)
+	if err != nil {
+		return nil, err
+	}
+	var commits []Commit
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		parts := strings.SplitN(line, "\x00", 4)
+		if len(parts) != 4 {
+			continue
+		}
+		date, err := time.Parse(time.RFC3339, parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("could not parse the date of commit %s: %w", parts[0], err)
+		}
+		commits = append(commits, Commit{Hash: parts[0], Date: date, Author: parts[2], Subject: parts[3]})
+	}
+	return commits, nil
+}
+
+// Show returns the content of a file in a revision, the path is relative to the dir of the runner
+func (r Runner) Show(rev string, path string) ([]byte, error) {
+	return r.Run("show", rev+":./"+path)
+}
+
+// Conflicts returns the files left unmerged by a merge, relative to the dir of the runner
+func (r Runner) Conflicts() ([]string, error) {
+	out, err := r.Run("diff", "--name-only", "--diff-filter=U", "--relative", "-z")
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, p := range strings.Split(string(out), "\x00") {
+		if len(p) > 0 && (len(paths) == 0 || paths[len(paths)-1] != p) {
+			paths = append(paths, p)
+		}
+	}
+	return paths, nil
+}
+
+// Unmerged returns the versions of a file left unmerged, i.e. the common ancestor, ours and theirs. A version is nil
+// if the file does not exist in it, e.g. the base of a file both added or ours of a file we removed
+func (r Runner) Unmerged(path string) (base []byte, ours []byte, theirs []byte, err error) {
+	out, err := r.Run("ls-files", "--unmerged", "-z", "--", "./"+path)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	stages := make([][]byte, 3)
+	for _, e := range strings.Split(string(out), "\x00") {
+		// e.g. 100644 4b825dc642cb6eb9a060e54bf8d69288fbee4904 2\tlib/2022/08/a.md
+		info, _, found := strings.Cut(e, "\t")
+		fields := strings.Fields(info)
+		if !found || len(fields) != 3 {
+			continue
+		}
+		stage, err := strconv.Atoi(fields[2])
+		if err != nil || stage < 1 || stage > 3 {
+			continue
+		}
+		data, err := r.Run("cat-file", "blob", fields[1])
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		stages[stage-1] = data
+	}
+	return stages[0], stages[1], stages[2], nil
+}
+
+// MergeFile merges the changes of ours and theirs since base line by line, and reports whether they clashed, in
+// which case the merged content has conflict markers
+func (r Runner) MergeFile(base []byte, ours []byte, theirs []byte) ([]byte, bool, error) {
+	dir, err := os.MkdirTemp("", "mark-merge-")
+	if err != nil {
+		return nil, false, err
+	}
+	defer os.RemoveAll(dir)
+	var files []string
+	for _, f := range []struct {
+		name string
+		data []byte
+	}{{"ours", ours}, {"base", base}, {"theirs", theirs}} {
+		file := filepath.Join(dir, f.name)
+		err = os.WriteFile(file, f.data, 0600)
+		if err != nil {
+			return nil, false, err
+		}
+		files = append(files, file)
+	}
+
+	out, err := r.Run(append([]string{"merge-file", "--stdout"}, files...)...)
+	// the exit code is the number of clashes, or negative on errors
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 && exitErr.ExitCode() < 128 {
+		return out, true, nil
+	}
+	return out, false, err
+}
@@ -0,0 +1,173 @@
+package git
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testRepos returns a bare repo acting as the remote, and a clone of it. It skips the test if git is not installed
+func testRepos(t *testing.T) (string, Runner) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	for _, env := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+		t.Setenv(env, "mark")
+	}
+	for _, env := range []string{"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(env, "mark@example.com")
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+
+	dir := t.TempDir()
+	remote := filepath.Join(dir, "remote.git")
+	_, err := New(dir).Run("init", "--quiet", "--bare", remote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = New(dir).Run("clone", "--quiet", remote, "work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return remote, New(filepath.Join(dir, "work"))
+}
+
+func write(t *testing.T, r Runner, name string, data string) {
+	t.Helper()
+	err := os.MkdirAll(filepath.Dir(filepath.Join(r.Dir, name)), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(r.Dir, name), []byte(data), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunner(t *testing.T) {
+	remote, r := testRepos(t)
+	if !r.IsRepo() {
+		t.Fatal("expected the clone to be a repo")
+	}
+	if New(t.TempDir()).IsRepo() {
+		t.Fatal("expected an empty dir to not be a repo")
+	}
+
+	write(t, r, "lib/2022/08/a.md", "a")
+	write(t, r, "lib/2022/08/b.md", "b")
+	changes, err := r.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Change{{Status: Added, Path: "lib/2022/08/a.md"}, {Status: Added, Path: "lib/2022/08/b.md"}}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("expected %v, got %v", expected, changes)
+	}
+	err = r.AddAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.Commit("first")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	write(t, r, "lib/2022/08/a.md", "a, edited")
+	err = os.Remove(filepath.Join(r.Dir, "lib/2022/08/b.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes, err = r.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []Change{{Status: Modified, Path: "lib/2022/08/a.md"}, {Status: Deleted, Path: "lib/2022/08/b.md"}}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("expected %v, got %v", expected, changes)
+	}
+	// the status is relative to the dir of the runner
+	changes, err = New(filepath.Join(r.Dir, "lib")).Status()
+	if err != nil || len(changes) != 2 || changes[0].Path != "2022/08/a.md" {
+		t.Fatalf("expected the paths to be relative to lib, got %v, %v", changes, err)
+	}
+	err = r.AddAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.Commit("second")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commits, err := r.Log("lib/2022/08/a.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commits) != 2 || commits[0].Subject != "second" || commits[1].Subject != "first" || commits[0].Author != "mark" {
+		t.Fatalf("expected the two commits of a.md, got %v", commits)
+	}
+	data, err := r.Show("HEAD~1", "lib/2022/08/a.md")
+	if err != nil || string(data) != "a" {
+		t.Fatalf("expected the first version of a.md, got %q, %v", data, err)
+	}
+
+	branch, err := r.Branch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.HasRemoteBranch("origin", branch) {
+		t.Fatal("expected the branch to not be on the remote before it is pushed")
+	}
+	err = r.Push("origin", branch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.HasRemoteBranch("origin", branch) {
+		t.Fatal("expected the branch to be on the remote after it is pushed")
+	}
+
+	other := New(filepath.Join(filepath.Dir(r.Dir), "other"))
+	_, err = New(filepath.Dir(r.Dir)).Run("clone", "--quiet", remote, "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	write(t, other, "lib/2022/08/c.md", "c")
+	for _, err := range []error{other.AddAll(), other.Commit("third"), other.Push("", "")} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = r.Pull("origin", branch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(r.Dir, "lib/2022/08/c.md")); err != nil {
+		t.Fatalf("expected the note of the other clone to be pulled, %v", err)
+	}
+
+	err = r.Commit("nothing")
+	var gitErr *Error
+	if !errors.As(err, &gitErr) || len(gitErr.Args) == 0 || gitErr.Args[0] != "commit" {
+		t.Fatalf("expected a git error committing nothing, got %v", err)
+	}
+}
+
+func TestMergeFile(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	r := New(t.TempDir())
+	base := []byte("a\n\nb\n\nc\n")
+	merged, clashed, err := r.MergeFile(base, []byte("a, ours\n\nb\n\nc\n"), []byte("a\n\nb\n\nc, theirs\n"))
+	if err != nil || clashed || string(merged) != "a, ours\n\nb\n\nc, theirs\n" {
+		t.Fatalf("expected a clean merge, got %q, %v, %v", merged, clashed, err)
+	}
+	_, clashed, err = r.MergeFile(base, []byte("a, ours\n\nb\n\nc\n"), []byte("a, theirs\n\nb\n\nc\n"))
+	if err != nil || !clashed {
+		t.Fatalf("expected the merge to clash, got %v, %v", clashed, err)
+	}
+}
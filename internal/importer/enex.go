@@ -0,0 +1,219 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/crholm/mark"
+	"github.com/crholm/mark/internal/ts"
+	"golang.org/x/net/html"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type enexNote struct {
+	Title   string   `xml:"title"`
+	Content string   `xml:"content"`
+	Created string   `xml:"created"`
+	Updated string   `xml:"updated"`
+	Tags    []string `xml:"tag"`
+	Source  string   `xml:"note-attributes>source-url"`
+}
+
+type enexExport struct {
+	Notes []enexNote `xml:"note"`
+}
+
+const enexTime = "20060102T150405Z"
+
+// Enex imports an Evernote export, which is also what Joplin exports to and from. The ENML content of notes is
+// converted to markdown
+func Enex(path string) ([]Note, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var export enexExport
+	dec := xml.NewDecoder(f)
+	dec.Strict = false
+	err = dec.Decode(&export)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode %s: %w", path, err)
+	}
+
+	var notes []Note
+	for i, n := range export.Notes {
+		created, err := time.Parse(enexTime, n.Created)
+		if err != nil {
+			return nil, fmt.Errorf("note %d (%s) has an invalid created time: %w", i+1, n.Title, err)
+		}
+		updated, err := time.Parse(enexTime, n.Updated)
+		if err != nil {
+			updated = created
+		}
+
+		content, err := enmlToMarkdown(n.Content)
+		if err != nil {
+			return nil, fmt.Errorf("note %d (%s): %w", i+1, n.Title, err)
+		}
+		if len(n.Source) > 0 {
+			content = append(content, []byte("\n\nSource: "+n.Source)...)
+		}
+		content = ts.EnsureTags(content, normalizeTags(n.Tags))
+
+		notes = append(notes, Note{
+			Source: fmt.Sprintf("%s#%d", path, i+1),
+			Header: mark.Header{
+				Title:     strings.TrimSpace(n.Title),
+				Tags:      ts.GetTagsFromNote(content),
+				CreatedAt: created.In(time.Local),
+				UpdatedAt: updated.In(time.Local),
+			},
+			Content: content,
+		})
+	}
+	return notes, nil
+}
+
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+// enmlToMarkdown does a best effort conversion of the html subset used by Evernote to markdown
+func enmlToMarkdown(enml string) ([]byte, error) {
+	z := html.NewTokenizer(strings.NewReader(enml))
+
+	var buf bytes.Buffer
+	var lists []string // "ul" or "ol" for each level of nesting
+	var counters []int
+	var href string
+	pre := false
+
+	newline := func() {
+		if buf.Len() > 0 && !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+			buf.WriteString("\n")
+		}
+	}
+	block := func() {
+		newline()
+		if buf.Len() > 0 && !bytes.HasSuffix(buf.Bytes(), []byte("\n\n")) {
+			buf.WriteString("\n")
+		}
+	}
+
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			if errors.Is(z.Err(), io.EOF) {
+				return bytes.TrimSpace(blankLines.ReplaceAll(buf.Bytes(), []byte("\n\n"))), nil
+			}
+			return nil, z.Err()
+		case html.TextToken:
+			raw := string(z.Text())
+			text := raw
+			if !pre {
+				text = strings.Join(strings.Fields(raw), " ")
+				if len(text) > 0 && strings.TrimLeft(raw, " \t\n") != raw && buf.Len() > 0 && !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+					text = " " + text
+				}
+				if len(text) > 0 && strings.TrimRight(raw, " \t\n") != raw {
+					text = text + " "
+				}
+			}
+			buf.WriteString(text)
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			attrs := map[string]string{}
+			for hasAttr {
+				var k, v []byte
+				k, v, hasAttr = z.TagAttr()
+				attrs[string(k)] = string(v)
+			}
+			switch string(name) {
+			case "h1", "h2", "h3", "h4", "h5", "h6":
+				block()
+				buf.WriteString(strings.Repeat("#", int(name[1]-'0')) + " ")
+			case "p", "div", "table":
+				block()
+			case "br", "tr":
+				newline()
+			case "td", "th":
+				buf.WriteString(" | ")
+			case "hr":
+				block()
+				buf.WriteString("---\n\n")
+			case "b", "strong":
+				buf.WriteString("**")
+			case "i", "em":
+				buf.WriteString("_")
+			case "code":
+				if !pre {
+					buf.WriteString("`")
+				}
+			case "pre":
+				block()
+				buf.WriteString("```\n")
+				pre = true
+			case "ul", "ol":
+				newline()
+				lists = append(lists, string(name))
+				counters = append(counters, 0)
+			case "li":
+				newline()
+				indent := ""
+				if len(lists) > 1 {
+					indent = strings.Repeat("  ", len(lists)-1)
+				}
+				if len(lists) > 0 && lists[len(lists)-1] == "ol" {
+					counters[len(counters)-1]++
+					buf.WriteString(fmt.Sprintf("%s%d. ", indent, counters[len(counters)-1]))
+				} else {
+					buf.WriteString(indent + "- ")
+				}
+			case "en-todo":
+				if attrs["checked"] == "true" {
+					buf.WriteString("- [x] ")
+				} else {
+					buf.WriteString("- [ ] ")
+				}
+			case "a":
+				href = attrs["href"]
+				buf.WriteString("[")
+			case "en-media":
+				buf.WriteString(fmt.Sprintf("(attachment %s)", attrs["type"]))
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "h1", "h2", "h3", "h4", "h5", "h6", "p", "div", "table":
+				block()
+			case "b", "strong":
+				buf.WriteString("**")
+			case "i", "em":
+				buf.WriteString("_")
+			case "code":
+				if !pre {
+					buf.WriteString("`")
+				}
+			case "pre":
+				newline()
+				buf.WriteString("```\n\n")
+				pre = false
+			case "ul", "ol":
+				if len(lists) > 0 {
+					lists = lists[:len(lists)-1]
+					counters = counters[:len(counters)-1]
+				}
+				block()
+			case "a":
+				buf.WriteString("](" + href + ")")
+				href = ""
+			}
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package importer
+
+import "testing"
+
+func TestEnmlToMarkdown(t *testing.T) {
+	for _, c := range []struct {
+		enml     string
+		expected string
+	}{
+		{"<en-note><div>Hello <b>world</b></div></en-note>", "Hello **world**"},
+		{"<h1>Title</h1><p>First</p><p>Second <i>line</i></p>", "# Title\n\nFirst\n\nSecond _line_"},
+		{"<ul><li>one</li><li>two<ul><li>nested</li></ul></li></ul>", "- one\n- two\n  - nested"},
+		{"<ol><li>first</li><li>second</li></ol>", "1. first\n2. second"},
+		{`<div><en-todo checked="true"/>done</div><div><en-todo/>todo</div>`, "- [x] done\n\n- [ ] todo"},
+		{`<a href="https://example.com">a link</a>`, "[a link](https://example.com)"},
+		{"<pre>line 1\n  line 2</pre>", "```\nline 1\n  line 2\n```"},
+		{"<div>use <code>go test</code></div>", "use `go test`"},
+		{"<div>line<br/>break</div>", "line\nbreak"},
+		{`<en-media type="image/png" hash="abc"/>`, "(attachment image/png)"},
+	} {
+		md, err := enmlToMarkdown(c.enml)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(md) != c.expected {
+			t.Fatalf("expected %q to be converted to %q, got %q", c.enml, c.expected, md)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package importer
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/crholm/mark"
+	"github.com/crholm/mark/internal/ts"
+	"gopkg.in/yaml.v3"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Note is a note converted from another tool, ready to be saved by fss.SaveNote
+type Note struct {
+	Source  string
+	Header  mark.Header
+	Content []byte
+}
+
+type Importer func(path string) ([]Note, error)
+
+func Of(from string) (Importer, error) {
+	switch from {
+	case "obsidian":
+		return Obsidian, nil
+	case "markdown-dir", "markdown":
+		return MarkdownDir, nil
+	case "enex":
+		return Enex, nil
+	default:
+		return nil, fmt.Errorf("unknown import source %s, expected obsidian, markdown-dir or enex", from)
+	}
+}
+
+// MarkdownDir imports all .md files in a directory tree. Relative links to other .md files are converted to [[links]]
+func MarkdownDir(root string) ([]Note, error) {
+	return walkMarkdown(root, nil, convertMarkdownLinks)
+}
+
+// Obsidian imports a vault, converting [[wiki links]] with headings and nested #tags/like/this to the syntax of mark
+func Obsidian(root string) ([]Note, error) {
+	return walkMarkdown(root, []string{".obsidian", ".trash"}, convertMarkdownLinks, convertWikiLinks, convertNestedTags)
+}
+
+func walkMarkdown(root string, skip []string, converters ...func([]byte) []byte) ([]Note, error) {
+	var notes []Note
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			for _, s := range skip {
+				if d.Name() == s {
+					return filepath.SkipDir
+				}
+			}
+			return nil
+		}
+		if strings.ToLower(filepath.Ext(path)) != ".md" {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		note, err := fromMarkdown(path, data, info.ModTime())
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		for _, convert := range converters {
+			note.Content = convert(note.Content)
+		}
+		note.Header.Tags = ts.GetTagsFromNote(note.Content)
+		notes = append(notes, note)
+		return nil
+	})
+	return notes, err
+}
+
+// fromMarkdown converts a markdown file with optional front matter. The name of the file becomes the alias of the
+// note, so that links by filename in the original tool can be resolved. Front matter unknown to mark is kept as is
+func fromMarkdown(path string, data []byte, modTime time.Time) (Note, error) {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	note := Note{
+		Source: path,
+		Header: mark.Header{
+			Title:     name,
+			Alias:     name,
+			CreatedAt: modTime,
+			UpdatedAt: modTime,
+		},
+	}
+
+	content := bytes.TrimSpace(data)
+	front := map[string]interface{}{}
+	if bytes.HasPrefix(content, []byte("---")) {
+		header, body, found := bytes.Cut(bytes.TrimLeft(content, "-\r\n"), []byte("\n---"))
+		if !found {
+			return note, fmt.Errorf("could not find end of front matter")
+		}
+		err := yaml.Unmarshal(header, &front)
+		if err != nil {
+			return note, err
+		}
+		content = bytes.TrimSpace(bytes.TrimLeft(body, "-"))
+	}
+
+	var tags []string
+	for key, value := range front {
+		switch key {
+		case "title":
+			note.Header.Title = fmt.Sprint(value)
+		case "tags", "tag":
+			tags = append(tags, stringsOf(value)...)
+		case "created_at", "created", "date":
+			if t, ok := timeOf(value); ok {
+				note.Header.CreatedAt = t
+			}
+		case "updated_at", "updated", "modified":
+			if t, ok := timeOf(value); ok {
+				note.Header.UpdatedAt = t
+			}
+		default:
+			if note.Header.Extra == nil {
+				note.Header.Extra = map[string]interface{}{}
+			}
+			note.Header.Extra[key] = value
+		}
+	}
+	note.Content = ts.EnsureTags(content, normalizeTags(tags))
+	return note, nil
+}
+
+// stringsOf reads a yaml value that is either a list or a comma/space separated string
+func stringsOf(value interface{}) []string {
+	var res []string
+	switch v := value.(type) {
+	case []interface{}:
+		for _, e := range v {
+			res = append(res, strings.TrimSpace(fmt.Sprint(e)))
+		}
+	case string:
+		res = strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ' ' })
+	case nil:
+	default:
+		res = append(res, fmt.Sprint(v))
+	}
+	return res
+}
+
+func timeOf(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02"} {
+			t, err := time.ParseInLocation(layout, strings.TrimSpace(v), time.Local)
+			if err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+func normalizeTags(tags []string) []string {
+	var res []string
+	for _, tag := range tags {
+		tag = strings.TrimLeft(strings.TrimSpace(tag), "#")
+		tag = strings.NewReplacer("/", "-", " ", "-").Replace(tag)
+		if len(tag) > 0 {
+			res = append(res, tag)
+		}
+	}
+	return res
+}
+
+var markdownLink = regexp.MustCompile(`(!?)\[([^\]]*)]\(([^)\s]+\.md)\)`)
+
+// convertMarkdownLinks converts [label](other%20note.md) to [[other note|label]], leaving embeds, i.e. ![x](y.md)
+func convertMarkdownLinks(content []byte) []byte {
+	return markdownLink.ReplaceAllFunc(content, func(m []byte) []byte {
+		parts := markdownLink.FindSubmatch(m)
+		target := string(parts[3])
+		if len(parts[1]) > 0 || strings.Contains(target, "://") {
+			return m
+		}
+		if unescaped, err := url.PathUnescape(target); err == nil {
+			target = unescaped
+		}
+		return wikiLink(strings.TrimSuffix(filepath.Base(target), filepath.Ext(target)), string(parts[2]))
+	})
+}
+
+var obsidianLink = regexp.MustCompile(`(!?)\[\[([^\]|#]*)(#[^\]|]*)?(\|[^\]]*)?]]`)
+
+// convertWikiLinks converts [[folder/Note#Heading|label]] to [[Note|label]], embedded notes ![[Note]] becomes links
+// while embedded files, e.g. ![[image.png]], becomes regular markdown images
+func convertWikiLinks(content []byte) []byte {
+	return obsidianLink.ReplaceAllFunc(content, func(m []byte) []byte {
+		parts := obsidianLink.FindSubmatch(m)
+		target := string(parts[2])
+		ext := filepath.Ext(target)
+		if len(parts[1]) > 0 && len(ext) > 0 && ext != ".md" {
+			return []byte("![" + filepath.Base(target) + "](" + (&url.URL{Path: target}).String() + ")")
+		}
+		// e.g. [[#Heading]] of the same note
+		if len(target) == 0 {
+			return m
+		}
+		target = strings.TrimSuffix(filepath.Base(target), ".md")
+		return wikiLink(target, strings.TrimPrefix(string(parts[4]), "|"))
+	})
+}
+
+var nestedTag = regexp.MustCompile(`#[0-9a-zA-ZÀ-ÖØ-öø-ÿĀ-ƿ_-]+(/[0-9a-zA-ZÀ-ÖØ-öø-ÿĀ-ƿ_-]+)+`)
+
+// convertNestedTags converts #nested/tags to #nested-tags since / is not part of a tag in mark
+func convertNestedTags(content []byte) []byte {
+	return nestedTag.ReplaceAllFunc(content, func(m []byte) []byte {
+		return bytes.ReplaceAll(m, []byte("/"), []byte("-"))
+	})
+}
+
+func wikiLink(target, label string) []byte {
+	if len(label) == 0 || label == target {
+		return []byte("[[" + target + "]]")
+	}
+	return []byte("[[" + target + "|" + label + "]]")
+}
@@ -0,0 +1,97 @@
+package importer
+
+import (
+	"github.com/crholm/mark"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConvertMarkdownLinks(t *testing.T) {
+	for content, expected := range map[string]string{
+		"see [the todo](todo.md)":                   "see [[todo|the todo]]",
+		"see [todo](todo.md)":                       "see [[todo]]",
+		"see [meeting](work/Meeting%20notes.md)":    "see [[Meeting notes|meeting]]",
+		"see [site](https://example.com/readme.md)": "see [site](https://example.com/readme.md)",
+		"see [image](photo.png)":                    "see [image](photo.png)",
+		"embeds ![diagram](diagram.md) are kept":    "embeds ![diagram](diagram.md) are kept",
+	} {
+		converted := string(convertMarkdownLinks([]byte(content)))
+		if converted != expected {
+			t.Fatalf("expected %q to be converted to %q, got %q", content, expected, converted)
+		}
+	}
+}
+
+func TestConvertWikiLinks(t *testing.T) {
+	for content, expected := range map[string]string{
+		"[[Note]]":                        "[[Note]]",
+		"[[folder/Note#Heading|label]]":   "[[Note|label]]",
+		"[[Note.md]]":                     "[[Note]]",
+		"![[Embedded note]]":              "[[Embedded note]]",
+		"![[assets/my image.png]]":        "![my image.png](assets/my%20image.png)",
+		"[[#Heading of the same note]]":   "[[#Heading of the same note]]",
+		"text without links [not a link]": "text without links [not a link]",
+	} {
+		converted := string(convertWikiLinks([]byte(content)))
+		if converted != expected {
+			t.Fatalf("expected %q to be converted to %q, got %q", content, expected, converted)
+		}
+	}
+}
+
+func TestConvertNestedTags(t *testing.T) {
+	for content, expected := range map[string]string{
+		"#work/project/mark and #home": "#work-project-mark and #home",
+		"a path a/b is not a tag":      "a path a/b is not a tag",
+	} {
+		converted := string(convertNestedTags([]byte(content)))
+		if converted != expected {
+			t.Fatalf("expected %q to be converted to %q, got %q", content, expected, converted)
+		}
+	}
+}
+
+func TestFromMarkdown(t *testing.T) {
+	modTime := time.Date(2022, 8, 12, 14, 4, 49, 0, time.UTC)
+	for _, c := range []struct {
+		data     string
+		header   mark.Header
+		expected string
+	}{
+		{
+			"Just content",
+			mark.Header{Title: "Note", Alias: "Note", CreatedAt: modTime, UpdatedAt: modTime},
+			"Just content",
+		},
+		{
+			"---\ntitle: Meeting\ntags: work, project/mark\ncreated: 2021-01-01\nstatus: done\n---\n\nAbout #kubernetes",
+			mark.Header{
+				Title:     "Meeting",
+				Alias:     "Note",
+				CreatedAt: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+				UpdatedAt: modTime,
+				Extra:     map[string]interface{}{"status": "done"},
+			},
+			"About #kubernetes\n\n#work #project-mark",
+		},
+		{
+			"---\ntags: [home]\n---\nBuy milk #home",
+			mark.Header{Title: "Note", Alias: "Note", CreatedAt: modTime, UpdatedAt: modTime},
+			"Buy milk #home",
+		},
+	} {
+		note, err := fromMarkdown("vault/Note.md", []byte(c.data), modTime)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(note.Header, c.header) || string(note.Content) != c.expected {
+			t.Fatalf("expected %+v and %q, got %+v and %q", c.header, c.expected, note.Header, note.Content)
+		}
+	}
+
+	_, err := fromMarkdown("vault/Note.md", []byte("---\ntitle: no end of front matter"), modTime)
+	if err == nil {
+		t.Fatal("expected an error for front matter without an end")
+	}
+}
@@ -0,0 +1,140 @@
+package merge
+
+import (
+	"bytes"
+	"github.com/crholm/mark"
+	"github.com/modfin/henry/slicez"
+	"reflect"
+)
+
+// BodyMerger merges the bodies of two versions of a note with their common ancestor, and reports whether they
+// clashed, i.e. changed the same lines, e.g. git merge-file
+type BodyMerger func(base, ours, theirs []byte) (merged []byte, clashed bool, err error)
+
+// Result is a note merged from two versions of it
+type Result struct {
+	Header  mark.Header
+	Content []byte
+
+	// Conflict is set when the bodies clashed, the note then has our body and the body of theirs is kept in
+	// Theirs, e.g. to be saved as a conflict copy
+	Conflict      bool
+	Theirs        mark.Header
+	TheirsContent []byte
+}
+
+// Note merges two versions of a note with their common ancestor, base, which is empty if both added the note. The
+// bodies are merged by body if both changed them, unless encrypted, which is then a conflict
+func Note(base, ours, theirs []byte, body BodyMerger) (Result, error) {
+	var baseHeader mark.Header
+	var baseContent []byte
+	if len(base) > 0 {
+		var err error
+		baseHeader, baseContent, err = mark.UnmarshalNote(base)
+		if err != nil {
+			return Result{}, err
+		}
+	}
+	ourHeader, ourContent, err := mark.UnmarshalNote(ours)
+	if err != nil {
+		return Result{}, err
+	}
+	theirHeader, theirContent, err := mark.UnmarshalNote(theirs)
+	if err != nil {
+		return Result{}, err
+	}
+
+	res := Result{Header: Headers(baseHeader, ourHeader, theirHeader)}
+	switch {
+	case bytes.Equal(ourContent, theirContent), bytes.Equal(theirContent, baseContent):
+		res.Content = ourContent
+	case bytes.Equal(ourContent, baseContent):
+		res.Content = theirContent
+	case ourHeader.Encrypted || theirHeader.Encrypted:
+		// encrypted bodies can not be merged line by line
+		res.Conflict = true
+	default:
+		merged, clashed, err := body(baseContent, ourContent, theirContent)
+		if err != nil {
+			return Result{}, err
+		}
+		res.Content = merged
+		res.Conflict = clashed
+	}
+	if res.Conflict {
+		res.Header.Encrypted = ourHeader.Encrypted
+		res.Content = ourContent
+		res.Theirs = theirHeader
+		res.TheirsContent = theirContent
+	}
+	return res, nil
+}
+
+// Headers merges two versions of a header with their common ancestor. Tags and attachments are the union of both,
+// UpdatedAt is the latest of both and other fields are the one that changed, or ours if both changed
+func Headers(base, ours, theirs mark.Header) mark.Header {
+	pick := func(b, o, t string) string {
+		if o == b {
+			return t
+		}
+		return o
+	}
+
+	h := ours
+	h.Title = pick(base.Title, ours.Title, theirs.Title)
+	h.Alias = pick(base.Alias, ours.Alias, theirs.Alias)
+	h.Kind = pick(base.Kind, ours.Kind, theirs.Kind)
+	if ours.Encrypted == base.Encrypted {
+		h.Encrypted = theirs.Encrypted
+	}
+	if h.CreatedAt.IsZero() {
+		h.CreatedAt = theirs.CreatedAt
+	}
+	if theirs.UpdatedAt.After(ours.UpdatedAt) {
+		h.UpdatedAt = theirs.UpdatedAt
+	}
+
+	h.Tags = slicez.Uniq(append(append([]string{}, ours.Tags...), theirs.Tags...))
+	h.Attachments = append([]mark.Attachment{}, ours.Attachments...)
+	for _, a := range theirs.Attachments {
+		if !slicez.Contains(h.Attachments, a) {
+			h.Attachments = append(h.Attachments, a)
+		}
+	}
+	if len(h.Attachments) == 0 {
+		h.Attachments = nil
+	}
+
+	h.Extra = mergeExtra(base.Extra, ours.Extra, theirs.Extra)
+	return h
+}
+
+// mergeExtra merges the unknown fields of headers key by key, like the fields of Headers
+func mergeExtra(base, ours, theirs map[string]interface{}) map[string]interface{} {
+	if len(ours) == 0 && len(theirs) == 0 {
+		return nil
+	}
+	res := map[string]interface{}{}
+	keys := map[string]bool{}
+	for _, m := range []map[string]interface{}{base, ours, theirs} {
+		for k := range m {
+			keys[k] = true
+		}
+	}
+	for k := range keys {
+		b, inBase := base[k]
+		o, inOurs := ours[k]
+		t, inTheirs := theirs[k]
+		if inOurs == inBase && reflect.DeepEqual(o, b) {
+			// unchanged by us, so whatever they did
+			if inTheirs {
+				res[k] = t
+			}
+			continue
+		}
+		if inOurs {
+			res[k] = o
+		}
+	}
+	return res
+}
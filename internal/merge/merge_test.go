@@ -0,0 +1,99 @@
+package merge
+
+import (
+	"github.com/crholm/mark"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHeaders(t *testing.T) {
+	created := time.Date(2022, 8, 12, 14, 4, 49, 0, time.UTC)
+	base := mark.Header{
+		Title:     "Groceries",
+		Alias:     "groceries",
+		Tags:      []string{"home"},
+		CreatedAt: created,
+		UpdatedAt: created,
+		Extra:     map[string]interface{}{"status": "open", "owner": "me"},
+	}
+	ours := base
+	ours.Title = "Groceries for the weekend"
+	ours.Tags = []string{"home", "weekend"}
+	ours.UpdatedAt = created.Add(time.Hour)
+	ours.Extra = map[string]interface{}{"status": "open", "owner": "you"}
+
+	theirs := base
+	theirs.Alias = "shopping"
+	theirs.Tags = []string{"shopping"}
+	theirs.UpdatedAt = created.Add(2 * time.Hour)
+	theirs.Attachments = []mark.Attachment{{Name: "list.png", Asset: "abc.png"}}
+	theirs.Extra = map[string]interface{}{"status": "done", "owner": "me"}
+
+	h := Headers(base, ours, theirs)
+	expected := mark.Header{
+		Title:       "Groceries for the weekend",
+		Alias:       "shopping",
+		Tags:        []string{"home", "weekend", "shopping"},
+		CreatedAt:   created,
+		UpdatedAt:   created.Add(2 * time.Hour),
+		Attachments: []mark.Attachment{{Name: "list.png", Asset: "abc.png"}},
+		Extra:       map[string]interface{}{"status": "done", "owner": "you"},
+	}
+	if !reflect.DeepEqual(h, expected) {
+		t.Fatalf("expected\n%+v\ngot\n%+v", expected, h)
+	}
+}
+
+func TestNote(t *testing.T) {
+	created := time.Date(2022, 8, 12, 14, 4, 49, 0, time.UTC)
+	note := func(updated time.Duration, content string) []byte {
+		data, err := mark.MarshalNote(mark.Header{Title: "Note", CreatedAt: created, UpdatedAt: created.Add(updated)}, []byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	var calls int
+	body := func(base, ours, theirs []byte) ([]byte, bool, error) {
+		calls++
+		if string(ours) == "clash" {
+			return nil, true, nil
+		}
+		return []byte("merged"), false, nil
+	}
+
+	for _, c := range []struct {
+		base, ours, theirs []byte
+		content            string
+		conflict           bool
+		calls              int
+	}{
+		// only one of them changed the body
+		{note(0, "a"), note(1, "a"), note(2, "b"), "b", false, 0},
+		{note(0, "a"), note(1, "b"), note(2, "a"), "b", false, 0},
+		// both made the same change
+		{note(0, "a"), note(1, "b"), note(2, "b"), "b", false, 0},
+		// both changed the body, which is merged line by line
+		{note(0, "a"), note(1, "b"), note(2, "c"), "merged", false, 1},
+		{note(0, "a"), note(1, "clash"), note(2, "c"), "clash", true, 1},
+		// both added the note
+		{nil, note(1, "b"), note(2, "c"), "merged", false, 1},
+	} {
+		calls = 0
+		res, err := Note(c.base, c.ours, c.theirs, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(res.Content) != c.content || res.Conflict != c.conflict || calls != c.calls {
+			t.Fatalf("expected %q, conflict %v and %d calls of the body merger, got %q, %v and %d",
+				c.content, c.conflict, c.calls, res.Content, res.Conflict, calls)
+		}
+		if !res.Header.UpdatedAt.Equal(created.Add(2)) {
+			t.Fatalf("expected the latest updated at, got %v", res.Header.UpdatedAt)
+		}
+		if c.conflict && string(res.TheirsContent) != "c" {
+			t.Fatalf("expected their body to be kept for a conflict copy, got %q", res.TheirsContent)
+		}
+	}
+}
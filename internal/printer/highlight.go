@@ -0,0 +1,123 @@
+package printer
+
+import (
+	"bytes"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const ansiHighlight = "\x1b[7m"
+const ansiHighlightOff = "\x1b[27m"
+
+var ansiSequence = regexp.MustCompile("\x1b\\[[0-9;]*[a-zA-Z]")
+var htmlMarkup = regexp.MustCompile(`<[^>]*>|&#?[0-9a-zA-Z]+;`)
+
+// matcher matches words starting with any of the terms, the same way a free text search matches on word prefixes
+func (o Options) matcher() *regexp.Regexp {
+	var terms []string
+	for _, t := range o.Terms {
+		t = strings.TrimSpace(t)
+		if len(t) > 0 {
+			terms = append(terms, regexp.QuoteMeta(t))
+		}
+	}
+	if len(terms) == 0 {
+		return nil
+	}
+	sort.Slice(terms, func(i, j int) bool { return len(terms[i]) > len(terms[j]) })
+	return regexp.MustCompile(`(?i)(?:` + strings.Join(terms, "|") + `)[\p{L}\p{N}_-]*`)
+}
+
+// ansiMatcher is the matcher of terms to highlight with ansi sequences, nil unless Color is set
+func (o Options) ansiMatcher() *regexp.Regexp {
+	if !o.Color {
+		return nil
+	}
+	return o.matcher()
+}
+
+// matches returns the indexes of matches that starts at the beginning of a word
+func matches(text []byte, re *regexp.Regexp) [][]int {
+	var res [][]int
+	for _, m := range re.FindAllIndex(text, -1) {
+		prev, _ := utf8.DecodeLastRune(text[:m[0]])
+		if m[0] > 0 && (unicode.IsLetter(prev) || unicode.IsDigit(prev)) {
+			continue
+		}
+		res = append(res, m)
+	}
+	return res
+}
+
+func highlight(text []byte, re *regexp.Regexp, open, close string) []byte {
+	if re == nil {
+		return text
+	}
+	var buf bytes.Buffer
+	last := 0
+	for _, m := range matches(text, re) {
+		buf.Write(text[last:m[0]])
+		buf.WriteString(open)
+		buf.Write(text[m[0]:m[1]])
+		buf.WriteString(close)
+		last = m[1]
+	}
+	buf.Write(text[last:])
+	return buf.Bytes()
+}
+
+// highlightBetween highlights matches in text, leaving what is matched by skip, e.g. ansi sequences or html tags, untouched
+func highlightBetween(text []byte, skip *regexp.Regexp, re *regexp.Regexp, open, close string) []byte {
+	if re == nil {
+		return text
+	}
+	var buf bytes.Buffer
+	last := 0
+	for _, s := range skip.FindAllIndex(text, -1) {
+		buf.Write(highlight(text[last:s[0]], re, open, close))
+		buf.Write(text[s[0]:s[1]])
+		last = s[1]
+	}
+	buf.Write(highlight(text[last:], re, open, close))
+	return buf.Bytes()
+}
+
+// snippet keeps only the lines of raw that matches, along with n lines of context around them. Hunks of lines
+// are separated by --, like grep does, surrounded by blank lines to not turn the line above into a markdown heading
+func snippet(raw []byte, re *regexp.Regexp, n int) []byte {
+	if re == nil {
+		return raw
+	}
+	lines := bytes.Split(raw, []byte("\n"))
+	keep := make([]bool, len(lines))
+	for i, line := range lines {
+		if len(matches(line, re)) == 0 {
+			continue
+		}
+		for j := i - n; j <= i+n; j++ {
+			if j >= 0 && j < len(lines) {
+				keep[j] = true
+			}
+		}
+	}
+
+	var hunks [][]byte
+	var hunk [][]byte
+	for i, line := range lines {
+		if keep[i] {
+			hunk = append(hunk, line)
+			continue
+		}
+		if len(hunk) > 0 {
+			hunks = append(hunks, bytes.Join(hunk, []byte("\n")))
+			hunk = nil
+		}
+	}
+	if len(hunk) > 0 {
+		hunks = append(hunks, bytes.Join(hunk, []byte("\n")))
+	}
+	return bytes.Join(hunks, []byte("\n\n--\n\n"))
+}
@@ -0,0 +1,117 @@
+package printer
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestMatcher(t *testing.T) {
+	for _, c := range []struct {
+		terms    []string
+		expected string
+	}{
+		{nil, ""},
+		{[]string{"", " "}, ""},
+		{[]string{"milk"}, `(?i)(?:milk)[\p{L}\p{N}_-]*`},
+		{[]string{"a", "#home", "c++"}, `(?i)(?:#home|c\+\+|a)[\p{L}\p{N}_-]*`},
+	} {
+		re := Options{Terms: c.terms}.matcher()
+		if re == nil {
+			if len(c.expected) > 0 {
+				t.Fatalf("expected a matcher for %v", c.terms)
+			}
+			continue
+		}
+		if re.String() != c.expected {
+			t.Fatalf("expected the matcher of %v to be %s, got %s", c.terms, c.expected, re)
+		}
+	}
+
+	if (Options{Terms: []string{"milk"}}).ansiMatcher() != nil {
+		t.Fatalf("expected no ansi highlighting without color")
+	}
+	if (Options{Terms: []string{"milk"}, Color: true}).ansiMatcher() == nil {
+		t.Fatalf("expected ansi highlighting with color")
+	}
+}
+
+func TestMatches(t *testing.T) {
+	re := Options{Terms: []string{"milk"}}.matcher()
+	for text, expected := range map[string][][]int{
+		"no match":            nil,
+		"milk":                {{0, 4}},
+		"Buy Milkshake":       {{4, 13}},
+		"buttermilk and milk": {{15, 19}},
+		"#milk, (milk) milk_": {{1, 5}, {8, 12}, {14, 19}},
+		"åmilk ömilk":         nil,
+	} {
+		m := matches([]byte(text), re)
+		if !reflect.DeepEqual(m, expected) {
+			t.Fatalf("expected the matches in %q to be %v, got %v", text, expected, m)
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	re := Options{Terms: []string{"milk"}}.matcher()
+	for _, c := range []struct {
+		text     string
+		expected string
+	}{
+		{"no match", "no match"},
+		{"Buy milk", "Buy <milk>"},
+		{"Milkshake and buttermilk, milk", "<Milkshake> and buttermilk, <milk>"},
+	} {
+		res := highlight([]byte(c.text), re, "<", ">")
+		if string(res) != c.expected {
+			t.Fatalf("expected %q, got %q", c.expected, res)
+		}
+	}
+	if string(highlight([]byte("milk"), nil, "<", ">")) != "milk" {
+		t.Fatalf("expected nothing to be highlighted without a matcher")
+	}
+}
+
+func TestHighlightBetween(t *testing.T) {
+	re := Options{Terms: []string{"mark"}}.matcher()
+	for _, c := range []struct {
+		text     string
+		skip     *regexp.Regexp
+		expected string
+	}{
+		{"<p>mark</p>", htmlMarkup, "<p>[mark]</p>"},
+		{`<mark class="mark">a mark</mark>`, htmlMarkup, `<mark class="mark">a [mark]</mark>`},
+		{"&mark; mark", htmlMarkup, "&mark; [mark]"},
+		{"\x1b[1mmark\x1b[0m", ansiSequence, "\x1b[1m[mark]\x1b[0m"},
+	} {
+		res := highlightBetween([]byte(c.text), c.skip, re, "[", "]")
+		if string(res) != c.expected {
+			t.Fatalf("expected %q, got %q", c.expected, res)
+		}
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	re := Options{Terms: []string{"milk"}}.matcher()
+	text := "1\n2 milk\n3\n4\n5\n6\n7 milk\n8"
+	for _, c := range []struct {
+		context  int
+		expected string
+	}{
+		{0, "2 milk\n\n--\n\n7 milk"},
+		{1, "1\n2 milk\n3\n\n--\n\n6\n7 milk\n8"},
+		{2, "1\n2 milk\n3\n4\n5\n6\n7 milk\n8"},
+	} {
+		res := snippet([]byte(text), re, c.context)
+		if string(res) != c.expected {
+			t.Fatalf("expected the snippet with %d lines of context to be %q, got %q", c.context, c.expected, res)
+		}
+	}
+	if len(snippet([]byte("no match"), re, 1)) != 0 {
+		t.Fatalf("expected an empty snippet without matches")
+	}
+	if string(snippet([]byte("no match"), nil, 1)) != "no match" {
+		t.Fatalf("expected everything without a matcher")
+	}
+}
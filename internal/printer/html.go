@@ -0,0 +1,51 @@
+package printer
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/crholm/mark"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"html"
+	"strings"
+	"time"
+)
+
+var markdown = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+// MarkdownToHTML renders the markdown content of a note to a html fragment
+func MarkdownToHTML(raw []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	err := markdown.Convert(raw, buf)
+	return buf.Bytes(), err
+}
+
+// HTMLPrinter renders each note as an article, which also breaks pages when printed from a browser
+func HTMLPrinter(opts Options) Printer {
+	re := opts.matcher()
+	return func(header mark.Header, raw []byte, _ string) ([]byte, error) {
+		body, err := MarkdownToHTML(raw)
+		if err != nil {
+			return nil, err
+		}
+		body = highlightBetween(body, htmlMarkup, re, "<mark>", "</mark>")
+		var tags []string
+		for _, tag := range header.Tags {
+			tags = append(tags, "#"+html.EscapeString(tag))
+		}
+
+		buf := bytes.NewBuffer(nil)
+		buf.WriteString("<article style=\"page-break-after: always\">\n")
+		if len(header.Title) > 0 {
+			fmt.Fprintf(buf, "<h1>%s</h1>\n", html.EscapeString(header.Title))
+		}
+		fmt.Fprintf(buf, "<p><time datetime=\"%s\">%s</time> %s</p>\n",
+			header.CreatedAt.Format(time.RFC3339),
+			html.EscapeString(opts.date(header.CreatedAt)),
+			strings.Join(tags, " "),
+		)
+		buf.Write(body)
+		buf.WriteString("</article>\n\n")
+		return buf.Bytes(), nil
+	}
+}
@@ -2,50 +2,177 @@ package printer
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"github.com/charmbracelet/glamour"
 	"github.com/crholm/mark"
+	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"text/template"
 	"time"
 )
 
-type Printer = func(header mark.Header, raw []byte, filename string) []byte
+type Printer = func(header mark.Header, raw []byte, filename string) ([]byte, error)
 
-func Of(printer string) Printer {
+const DefaultWidth = 110
+const DefaultDateLayout = "Monday Jan 02 2006 - 15:04:05"
+
+type Options struct {
+	// Template is the go text/template used by the template printer, e.g. '{{.Title}} {{.CreatedAt}}'
+	Template string
+
+	// Width of the formatted output, if 0 it is the width of the terminal, $COLUMNS or DefaultWidth
+	Width int
+	// Style is the name of a glamour style, e.g. dark, light or notty, or a path to a json style file.
+	// If empty $GLAMOUR_STYLE is used, or a style is selected depending on the terminal
+	Style string
+	// DateLayout is the go time layout used for the timestamp of notes, defaults to DefaultDateLayout
+	DateLayout string
+
+	// Terms are highlighted where they start a word in the content of notes, e.g. the terms of a search
+	Terms []string
+	// Snippets prints only the lines matching Terms along with Context number of lines around them
+	Snippets bool
+	Context  int
+	// Color highlights Terms with ansi sequences in the plain and formatted output, e.g. when stdout is a terminal
+	Color bool
+
+	// AssetPath returns the path of an attached asset, listed after the content of notes. Defaults to assets/<asset>
+	AssetPath func(asset string) string
+}
+
+func (o Options) width() int {
+	if o.Width > 0 {
+		return o.Width
+	}
+	if w, ok := terminalWidth(); ok {
+		return w
+	}
+	if w, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && w > 0 {
+		return w
+	}
+	return DefaultWidth
+}
+
+func (o Options) date(t time.Time) string {
+	layout := o.DateLayout
+	if len(layout) == 0 {
+		layout = DefaultDateLayout
+	}
+	return t.In(time.Local).Format(layout)
+}
+
+// attachments returns a line per attachment of a note
+func (o Options) attachments(header mark.Header) []string {
+	var lines []string
+	for _, a := range header.Attachments {
+		p := "assets/" + a.Asset
+		if o.AssetPath != nil {
+			p = o.AssetPath(a.Asset)
+		}
+		lines = append(lines, fmt.Sprintf("attachment: %s %s", a.Name, p))
+	}
+	return lines
+}
+
+func Of(printer string, opts Options) (Printer, error) {
+	p, err := of(printer, opts)
+	if err != nil || !opts.Snippets {
+		return p, err
+	}
+	re := opts.matcher()
+	if re == nil {
+		return p, nil
+	}
+	return func(header mark.Header, raw []byte, filename string) ([]byte, error) {
+		raw = snippet(raw, re, opts.Context)
+		if len(raw) == 0 {
+			return nil, nil
+		}
+		return p(header, raw, filename)
+	}, nil
+}
+
+func of(printer string, opts Options) (Printer, error) {
 	switch printer {
 	case "raw":
-		return RawPrinter
+		re := opts.matcher()
+		return func(header mark.Header, raw []byte, filename string) ([]byte, error) {
+			return RawPrinter(header, highlight(raw, re, "**", "**"), filename)
+		}, nil
 	case "plain":
-		return PlainPrinter
+		return PlainPrinter(opts), nil
 	case "annotated":
-		return AnnotatedPrinter
+		return AnnotatedPrinter, nil
+	case "html":
+		return HTMLPrinter(opts), nil
+	case "template":
+		return TemplatePrinter(opts.Template)
+	case "", "markdown", "formatted":
+		return FormattedPrinter(opts)
 	default:
-		return FormattedPrinter
+		return nil, fmt.Errorf("unknown format %s, expected markdown, plain, raw, annotated, html or template", printer)
 	}
 }
 
-func RawPrinter(header mark.Header, raw []byte, _ string) []byte {
-	data, err := mark.MarshalNote(header, raw)
+// TemplatePrinter executes a go text/template for each note, the template has access to the fields of the
+// header along with .Body and .File
+func TemplatePrinter(text string) (Printer, error) {
+	if len(text) == 0 {
+		return nil, errors.New("the template format requires a template, e.g. --output-template '{{.Title}} {{.CreatedAt}}'")
+	}
+	t, err := template.New("note").Parse(text)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	return append(append([]byte(nil), data...), []byte("\n")...)
+	return func(header mark.Header, raw []byte, file string) ([]byte, error) {
+		buf := bytes.NewBuffer(nil)
+		err := t.Execute(buf, struct {
+			mark.Header
+			Body string
+			File string
+		}{header, string(raw), filepath.Base(file)})
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+			buf.WriteString("\n")
+		}
+		return buf.Bytes(), nil
+	}, nil
 }
 
-func PlainPrinter(header mark.Header, raw []byte, _ string) []byte {
-	title := fmt.Sprintf("--- %s --- %s\n", header.CreatedAt.In(time.Local).Format("Monday Jan 02 2006 - 15:04:05"), header.Title)
+func RawPrinter(header mark.Header, raw []byte, _ string) ([]byte, error) {
+	data, err := mark.MarshalNote(header, raw)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte(nil), data...), []byte("\n")...), nil
+}
 
-	content := append([]byte(" "), bytes.ReplaceAll(raw, []byte("\n"), []byte("\n "))...)
-	footer := "\n\n"
-	return append(append([]byte(title), content...), []byte(footer)...)
+func PlainPrinter(opts Options) Printer {
+	re := opts.ansiMatcher()
+	return func(header mark.Header, raw []byte, _ string) ([]byte, error) {
+		title := fmt.Sprintf("--- %s --- %s\n", opts.date(header.CreatedAt), header.Title)
+		raw = highlight(raw, re, ansiHighlight, ansiHighlightOff)
 
+		content := append([]byte(" "), bytes.ReplaceAll(raw, []byte("\n"), []byte("\n "))...)
+		for _, line := range opts.attachments(header) {
+			content = append(content, []byte("\n "+line)...)
+		}
+		footer := "\n\n"
+		return append(append([]byte(title), content...), []byte(footer)...), nil
+	}
 }
 
-func AnnotatedPrinter(header mark.Header, raw []byte, file string) []byte {
+func AnnotatedPrinter(header mark.Header, raw []byte, file string) ([]byte, error) {
 	anno := []byte(filepath.Base(file) + ":")
-	content := RawPrinter(header, raw, "")
+	content, err := RawPrinter(header, raw, "")
+	if err != nil {
+		return nil, err
+	}
 
 	var res []byte
 	for i, line := range bytes.Split(content, []byte("\n")) {
@@ -54,42 +181,55 @@ func AnnotatedPrinter(header mark.Header, raw []byte, file string) []byte {
 		l = append(l, '\n')
 		res = append(res, l...)
 	}
-	return res
+	return res, nil
 }
 
-func FormattedPrinter(header mark.Header, raw []byte, _ string) []byte {
-	width := 110
+func FormattedPrinter(opts Options) (Printer, error) {
+	width := opts.width()
 
-	title := ""
-	if len(header.Title) > 0 {
-		title = fmt.Sprint(" ", header.Title, " ")
+	style := glamour.WithEnvironmentConfig()
+	if len(opts.Style) > 0 {
+		style = glamour.WithStylePath(opts.Style)
 	}
-
-	title = fmt.Sprintf("┌─%s────",
-		title,
-	)
-	timestamp := header.CreatedAt.In(time.Local).Format("Monday Jan 02 2006 - 15:04:05")
-	title = fmt.Sprintf("%s%s %s\n", title, strings.Repeat("─", width-len([]rune(title))-len(timestamp)-2), timestamp)
-
-	render, err := glamour.NewTermRenderer(glamour.WithEnvironmentConfig(), glamour.WithWordWrap(width))
+	render, err := glamour.NewTermRenderer(style, glamour.WithWordWrap(width))
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("could not create renderer with style %s: %w", opts.Style, err)
 	}
-	out, err := render.RenderBytes(raw)
-	if err != nil {
-		panic(err)
-	}
-	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
-	var content string
-	for i, s := range lines {
-		s := strings.TrimSpace(s)
-		content += "│" + s
-		// don't add an artificial newline after the last split
-		if i+1 < len(lines) {
-			content += "\n"
+	re := opts.ansiMatcher()
+
+	return func(header mark.Header, raw []byte, _ string) ([]byte, error) {
+		title := ""
+		if len(header.Title) > 0 {
+			title = fmt.Sprint(" ", header.Title, " ")
 		}
-	}
-	footer := "\n└" + strings.Repeat("─", len([]rune(title))-2) + "\n\n"
-	return append(append([]byte(title), []byte(content)...), []byte(footer)...)
 
+		title = fmt.Sprintf("┌─%s────",
+			title,
+		)
+		timestamp := opts.date(header.CreatedAt)
+		fill := width - len([]rune(title)) - len([]rune(timestamp)) - 2
+		if fill < 0 {
+			fill = 0
+		}
+		title = fmt.Sprintf("%s%s %s\n", title, strings.Repeat("─", fill), timestamp)
+
+		out, err := render.RenderBytes(raw)
+		if err != nil {
+			return nil, err
+		}
+		out = highlightBetween(out, ansiSequence, re, ansiHighlight, ansiHighlightOff)
+		lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+		lines = append(lines, opts.attachments(header)...)
+		var content string
+		for i, s := range lines {
+			s := strings.TrimSpace(s)
+			content += "│" + s
+			// don't add an artificial newline after the last split
+			if i+1 < len(lines) {
+				content += "\n"
+			}
+		}
+		footer := "\n└" + strings.Repeat("─", len([]rune(title))-2) + "\n\n"
+		return append(append([]byte(title), []byte(content)...), []byte(footer)...), nil
+	}, nil
 }
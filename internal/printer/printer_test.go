@@ -0,0 +1,144 @@
+package printer
+
+import (
+	"github.com/crholm/mark"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOf(t *testing.T) {
+	for _, format := range []string{"", "markdown", "formatted", "plain", "raw", "annotated", "html"} {
+		p, err := Of(format, Options{})
+		if err != nil || p == nil {
+			t.Fatalf("expected a printer for the format %q, got %v", format, err)
+		}
+	}
+	_, err := Of("bogus", Options{})
+	if err == nil || !strings.Contains(err.Error(), "unknown format bogus") {
+		t.Fatalf("expected an error for an unknown format, got %v", err)
+	}
+	_, err = Of("template", Options{})
+	if err == nil {
+		t.Fatal("expected an error for the template format without a template")
+	}
+}
+
+func TestTemplatePrinter(t *testing.T) {
+	header := mark.Header{Title: "Groceries", CreatedAt: time.Date(2022, 8, 12, 14, 4, 49, 0, time.UTC)}
+	for _, c := range []struct {
+		template string
+		expected string
+	}{
+		{"{{.Title}} {{.CreatedAt}}", "Groceries 2022-08-12 14:04:49 +0000 UTC\n"},
+		{"{{.File}}: {{.Body}}\n", "2022-08-12_14:04:49Z_Friday.md: Buy milk\n"},
+		{`{{.CreatedAt.Format "2006-01-02"}}`, "2022-08-12\n"},
+	} {
+		p, err := TemplatePrinter(c.template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := p(header, []byte("Buy milk"), "/home/user/.mark/lib/2022/08/2022-08-12_14:04:49Z_Friday.md")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(res) != c.expected {
+			t.Fatalf("expected %q to print %q, got %q", c.template, c.expected, res)
+		}
+	}
+
+	_, err := TemplatePrinter("{{.Title")
+	if err == nil {
+		t.Fatal("expected an error for a template that does not parse")
+	}
+}
+
+func TestHTMLPrinter(t *testing.T) {
+	header := mark.Header{
+		Title:     "<b>Bold</b> & co",
+		Tags:      []string{"home"},
+		CreatedAt: time.Date(2022, 8, 12, 14, 4, 49, 0, time.UTC),
+	}
+	data, err := HTMLPrinter(Options{})(header, []byte("# Agenda\n\n1 < 2 & <script>alert(1)</script>"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := string(data)
+	for _, expected := range []string{
+		`<article style="page-break-after: always">`,
+		"<h1>&lt;b&gt;Bold&lt;/b&gt; &amp; co</h1>",
+		`<time datetime="2022-08-12T14:04:49Z">`,
+		"#home",
+		"<h1>Agenda</h1>",
+		"1 &lt; 2 &amp;",
+	} {
+		if !strings.Contains(res, expected) {
+			t.Fatalf("expected %q in\n%s", expected, res)
+		}
+	}
+	if strings.Contains(res, "<script>") {
+		t.Fatalf("expected html in the body to be escaped or omitted, got\n%s", res)
+	}
+}
+
+func TestFormattedPrinter(t *testing.T) {
+	header := mark.Header{Title: "Groceries", CreatedAt: time.Date(2022, 8, 12, 14, 4, 49, 0, time.UTC)}
+	for _, width := range []int{40, 80} {
+		p, err := FormattedPrinter(Options{Width: width, Style: "notty", DateLayout: "2006-01-02"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := p(header, []byte(strings.Repeat("Buy milk and bread. ", 10)), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(res)), "\n")
+		title := lines[0]
+		if !strings.HasPrefix(title, "┌─ Groceries ─") || !strings.HasSuffix(title, " 2022-08-12") || len([]rune(title)) != width-1 {
+			t.Fatalf("expected a title line %d wide, got %q", width-1, title)
+		}
+		for _, l := range lines[1:] {
+			if len([]rune(l)) > width {
+				t.Fatalf("expected the content to be wrapped at %d, got %q", width, l)
+			}
+		}
+	}
+}
+
+func TestFormattedPrinterStyle(t *testing.T) {
+	dir := t.TempDir()
+	invalid := filepath.Join(dir, "invalid.json")
+	err := os.WriteFile(invalid, []byte("{not json"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, style := range []string{filepath.Join(dir, "missing.json"), invalid} {
+		_, err := FormattedPrinter(Options{Width: 80, Style: style})
+		if err == nil || !strings.Contains(err.Error(), "could not create renderer with style "+style) {
+			t.Fatalf("expected an error for the style %s, got %v", style, err)
+		}
+		_, err = Of("markdown", Options{Width: 80, Style: style})
+		if err == nil {
+			t.Fatalf("expected Of to return the error for the style %s", style)
+		}
+	}
+}
+
+func TestWidth(t *testing.T) {
+	if (Options{Width: 42}).width() != 42 {
+		t.Fatal("expected the width given by the options")
+	}
+	if _, ok := terminalWidth(); ok {
+		t.Skip("the width of the terminal takes precedence over $COLUMNS")
+	}
+	t.Setenv("COLUMNS", "60")
+	if (Options{}).width() != 60 {
+		t.Fatal("expected the width of $COLUMNS")
+	}
+	t.Setenv("COLUMNS", "")
+	if (Options{}).width() != DefaultWidth {
+		t.Fatal("expected the default width")
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !(aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris)
+
+package printer
+
+func terminalWidth() (int, bool) {
+	return 0, false
+}
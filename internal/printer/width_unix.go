@@ -0,0 +1,18 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+
+package printer
+
+import (
+	"golang.org/x/sys/unix"
+	"os"
+)
+
+func terminalWidth() (int, bool) {
+	for _, f := range []*os.File{os.Stdout, os.Stderr, os.Stdin} {
+		ws, err := unix.IoctlGetWinsize(int(f.Fd()), unix.TIOCGWINSZ)
+		if err == nil && ws.Col > 0 {
+			return int(ws.Col), true
+		}
+	}
+	return 0, false
+}
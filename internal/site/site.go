@@ -0,0 +1,305 @@
+package site
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/crholm/mark"
+	"github.com/crholm/mark/internal/fss"
+	"github.com/crholm/mark/internal/printer"
+	"github.com/modfin/henry/mapz"
+	"github.com/modfin/henry/slicez"
+	"html/template"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Page is a note to be rendered into the site
+type Page struct {
+	File    string // the filename of the note, e.g. 2022-08-12_14:04:49Z_Friday.md
+	Header  mark.Header
+	Content []byte
+}
+
+type Site struct {
+	Pages []Page
+	// Tags maps a tag to the filenames of the notes having it
+	Tags map[string][]string
+	// Words maps words of the free text index to the filenames of the notes containing them
+	Words map[string][]string
+	// Resolve resolves the target of a [[link]] to a filename
+	Resolve func(link string) (string, bool)
+	// Assets maps the name of assets attached to the notes to their content, they are written to assets/
+	Assets map[string][]byte
+}
+
+// PageURL returns the url of a note relative to the root of the site. Colons are not part of the name since
+// a relative url such as 2022-08-12_14:04:49Z_Friday.html would be read as having the scheme "2022-08-12_14"
+func PageURL(file string) string {
+	return "notes/" + strings.ReplaceAll(strings.TrimSuffix(file, ".md"), ":", "") + ".html"
+}
+
+// TagPath returns the path of the page of a tag relative to the root of the site, named by the tag as is
+func TagPath(tag string) string {
+	return filepath.Join("tags", tag+".html")
+}
+
+// TagURL returns the url of the page of a tag, escaped since tags may have letters such as å that are not ascii
+func TagURL(tag string) string {
+	return "tags/" + url.PathEscape(tag) + ".html"
+}
+
+type noteData struct {
+	URL   string   `json:"url"`
+	Title string   `json:"title"`
+	Date  string   `json:"date"`
+	Tags  []string `json:"tags"`
+}
+
+type pageData struct {
+	Root    string
+	Title   string
+	Notes   []noteData
+	Tags    []tagData
+	Note    *noteData
+	Updated string
+	Body    template.HTML
+}
+
+type tagData struct {
+	Name  string
+	URL   string
+	Count int
+}
+
+const dateLayout = "Monday Jan 02 2006 - 15:04"
+
+// Write renders the site into dir, i.e. index.html, tags.html, a page per tag in tags/, a page per note in notes/
+// search.js holding the data used for searching notes in the browser and the attached assets in assets/
+func Write(dir string, s Site) error {
+	for _, d := range []string{dir, filepath.Join(dir, "notes"), filepath.Join(dir, "tags"), filepath.Join(dir, "assets")} {
+		err := os.MkdirAll(d, 0755)
+		if err != nil {
+			return err
+		}
+	}
+	for name, data := range s.Assets {
+		err := os.WriteFile(filepath.Join(dir, "assets", filepath.Base(name)), data, 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	pages := slicez.SortFunc(s.Pages, func(a, b Page) bool {
+		return a.Header.CreatedAt.After(b.Header.CreatedAt)
+	})
+	notes := map[string]noteData{}
+	var all []noteData
+	for _, p := range pages {
+		title := strings.TrimSpace(p.Header.Title)
+		if len(title) == 0 {
+			title = strings.TrimSuffix(p.File, ".md")
+		}
+		n := noteData{
+			URL:   PageURL(p.File),
+			Title: title,
+			Date:  p.Header.CreatedAt.Format(dateLayout),
+			Tags:  p.Header.Tags,
+		}
+		notes[p.File] = n
+		all = append(all, n)
+	}
+
+	var tags []tagData
+	for _, tag := range slicez.Sort(mapz.Keys(s.Tags)) {
+		var tagged []noteData
+		for _, file := range slicez.Uniq(s.Tags[tag]) {
+			if n, ok := notes[file]; ok {
+				tagged = append(tagged, n)
+			}
+		}
+		if len(tagged) == 0 {
+			continue
+		}
+		tagged = slicez.SortFunc(tagged, func(a, b noteData) bool { return a.URL > b.URL })
+		tags = append(tags, tagData{Name: tag, URL: TagURL(tag), Count: len(tagged)})
+		err := render(filepath.Join(dir, TagPath(tag)), pageData{Root: "../", Title: "#" + tag, Notes: tagged})
+		if err != nil {
+			return err
+		}
+	}
+
+	err := render(filepath.Join(dir, "index.html"), pageData{Title: "Notes", Notes: all})
+	if err != nil {
+		return err
+	}
+	err = render(filepath.Join(dir, "tags.html"), pageData{Title: "Tags", Tags: tags})
+	if err != nil {
+		return err
+	}
+
+	for _, p := range pages {
+		n := notes[p.File]
+		content := linksToMarkdown(p.Content, s.Resolve, notes)
+		// assets are linked relative to the year/month dir of the note, and are in assets/ next to notes/ of the site
+		content = bytes.ReplaceAll(content, []byte(fss.AssetLink("")), []byte("../assets/"))
+		body, err := printer.MarkdownToHTML(content)
+		if err != nil {
+			return fmt.Errorf("could not render %s: %w", p.File, err)
+		}
+		err = render(filepath.Join(dir, n.URL), pageData{
+			Root:    "../",
+			Title:   n.Title,
+			Note:    &n,
+			Updated: p.Header.UpdatedAt.Format(dateLayout),
+			Body:    template.HTML(body),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return writeSearch(filepath.Join(dir, "search.js"), all, notes, s.Words)
+}
+
+var wikiLink = regexp.MustCompile(`\[\[([^\[\]|]+)(\|[^\[\]]*)?]]`)
+
+// linksToMarkdown replaces [[links]] with markdown links to the page of the note, or the label if it is not exported
+func linksToMarkdown(content []byte, resolve func(string) (string, bool), notes map[string]noteData) []byte {
+	return wikiLink.ReplaceAllFunc(content, func(m []byte) []byte {
+		parts := wikiLink.FindSubmatch(m)
+		target := strings.TrimSpace(string(parts[1]))
+		label := strings.TrimPrefix(string(parts[2]), "|")
+		if len(label) == 0 {
+			label = target
+		}
+		if resolve == nil {
+			return []byte(label)
+		}
+		file, ok := resolve(target)
+		n, exported := notes[file]
+		if !ok || !exported {
+			return []byte(label)
+		}
+		return []byte(fmt.Sprintf("[%s](../%s)", label, n.URL))
+	})
+}
+
+func writeSearch(file string, all []noteData, notes map[string]noteData, words map[string][]string) error {
+	position := map[string]int{}
+	for i, n := range all {
+		position[n.URL] = i
+	}
+	data := struct {
+		Notes []noteData       `json:"notes"`
+		Words map[string][]int `json:"words"`
+	}{Notes: all, Words: map[string][]int{}}
+
+	for word, files := range words {
+		var ids []int
+		for _, f := range slicez.Uniq(files) {
+			if n, ok := notes[f]; ok {
+				ids = append(ids, position[n.URL])
+			}
+		}
+		if len(ids) > 0 {
+			data.Words[word] = slicez.Sort(ids)
+		}
+	}
+
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, append(append([]byte("window.markSearch = "), b...), []byte(";\n")...), 0644)
+}
+
+func render(file string, data pageData) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return layout.Execute(f, data)
+}
+
+var layout = template.Must(template.New("layout").Funcs(template.FuncMap{"tagURL": TagURL}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; max-width: 50em; margin: 2em auto; padding: 0 1em; line-height: 1.5; color: #222; }
+nav a { margin-right: 1em; }
+ul.notes { list-style: none; padding: 0; }
+ul.notes li { margin: .4em 0; }
+.date, .meta { color: #777; font-size: .9em; }
+.tag { color: #36c; margin-right: .4em; }
+pre { background: #f4f4f4; padding: .8em; overflow-x: auto; }
+input#search { width: 100%; padding: .4em; font-size: 1em; }
+</style>
+</head>
+<body>
+<nav><a href="{{.Root}}index.html">Notes</a><a href="{{.Root}}tags.html">Tags</a></nav>
+<h1>{{.Title}}</h1>
+{{- if .Note}}
+<p class="meta">Created {{.Note.Date}} &middot; Updated {{.Updated}}
+{{- range .Note.Tags}} <a class="tag" href="{{$.Root}}{{tagURL .}}">#{{.}}</a>{{end}}</p>
+<article>
+{{.Body}}
+</article>
+{{- end}}
+{{- if .Tags}}
+<ul class="notes">
+{{- range .Tags}}
+<li><a class="tag" href="{{$.Root}}{{.URL}}">#{{.Name}}</a> <span class="date">{{.Count}}</span></li>
+{{- end}}
+</ul>
+{{- end}}
+{{- if .Notes}}
+{{- if not .Root}}
+<input id="search" type="search" placeholder="Search">
+{{- end}}
+<ul class="notes" id="notes">
+{{- range .Notes}}
+<li><a href="{{$.Root}}{{.URL}}">{{.Title}}</a> <span class="date">{{.Date}}</span>{{range .Tags}} <span class="tag">#{{.}}</span>{{end}}</li>
+{{- end}}
+</ul>
+{{- end}}
+{{- if and .Notes (not .Root)}}
+<script src="search.js"></script>
+<script>
+(function () {
+  var input = document.getElementById("search");
+  var items = document.getElementById("notes").children;
+  var words = Object.keys(window.markSearch.words);
+  input.addEventListener("input", function () {
+    var terms = input.value.toLowerCase().split(/[\s!-\/:-@\[-\x60{-~]+/).filter(function (t) { return t.length > 0; });
+    var hits = null;
+    terms.forEach(function (term) {
+      var found = {};
+      words.forEach(function (w) {
+        if (w.indexOf(term) === 0) {
+          window.markSearch.words[w].forEach(function (i) { found[i] = true; });
+        }
+      });
+      if (hits === null) {
+        hits = found;
+        return;
+      }
+      Object.keys(hits).forEach(function (i) { if (!found[i]) { delete hits[i]; } });
+    });
+    for (var i = 0; i < items.length; i++) {
+      items[i].style.display = hits === null || hits[i] ? "" : "none";
+    }
+  });
+})();
+</script>
+{{- end}}
+</body>
+</html>
+`))
@@ -0,0 +1,77 @@
+package site
+
+import (
+	"github.com/crholm/mark"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWrite(t *testing.T) {
+	dir := t.TempDir()
+	created := time.Date(2022, 8, 12, 14, 4, 49, 0, time.UTC)
+	s := Site{
+		Pages: []Page{
+			{
+				File:    "2022-08-12_14:04:49Z_Friday.md",
+				Header:  mark.Header{Title: "Berries", Tags: []string{"blåbär"}, CreatedAt: created, UpdatedAt: created},
+				Content: []byte("Pick #blåbär, see [[jam|the jam]] and [[missing]]"),
+			},
+			{
+				File:    "2022-08-13_14:04:49Z_Saturday.md",
+				Header:  mark.Header{Title: "Jam", CreatedAt: created.AddDate(0, 0, 1), UpdatedAt: created},
+				Content: []byte("Boil it"),
+			},
+		},
+		Tags:  map[string][]string{"blåbär": {"2022-08-12_14:04:49Z_Friday.md"}},
+		Words: map[string][]string{"boil": {"2022-08-13_14:04:49Z_Saturday.md"}},
+		Resolve: func(link string) (string, bool) {
+			return "2022-08-13_14:04:49Z_Saturday.md", link == "jam"
+		},
+		Assets: map[string][]byte{"abc.png": []byte("png")},
+	}
+	err := Write(dir, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	read := func(name string) string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	// the page of a tag is named by the tag, and linked to by the escaped name a browser asks for
+	tags := read("tags.html")
+	href := TagURL("blåbär")
+	if href != "tags/bl%C3%A5b%C3%A4r.html" || !strings.Contains(tags, `href="`+href+`"`) {
+		t.Fatalf("expected tags.html to link to %s, got\n%s", href, tags)
+	}
+	requested, err := url.PathUnescape(href)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page := read(requested); !strings.Contains(page, "Berries") {
+		t.Fatalf("expected the page of the tag to list the note, got\n%s", page)
+	}
+
+	berries := read(PageURL(s.Pages[0].File))
+	if !strings.Contains(berries, `<a href="../`+PageURL(s.Pages[1].File)+`">the jam</a>`) || !strings.Contains(berries, "missing") {
+		t.Fatalf("expected links to be rendered to the pages of the notes, got\n%s", berries)
+	}
+	if index := read("index.html"); strings.Index(index, "Jam") > strings.Index(index, "Berries") {
+		t.Fatalf("expected the index to list the newest note first, got\n%s", index)
+	}
+	if read("assets/abc.png") != "png" {
+		t.Fatal("expected the assets to be written")
+	}
+	if search := read("search.js"); !strings.Contains(search, `"boil":[0]`) {
+		t.Fatalf("expected the words to be searchable, got\n%s", search)
+	}
+}
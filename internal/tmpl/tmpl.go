@@ -0,0 +1,90 @@
+package tmpl
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/crholm/mark"
+	"github.com/crholm/mark/internal/fss"
+	"io/ioutil"
+	"os"
+	"text/template"
+	"time"
+)
+
+// Prompter asks the user for a value, used by {{prompt "Label"}}
+type Prompter func(label string) (string, error)
+
+// Load reads a template from .mark/templates/<name>.md, the template is a regular note where
+// the yaml front matter holds the defaults, e.g. title and tags, for notes created from it
+func Load(name string) (mark.Header, []byte, error) {
+	file, err := fss.GetTemplatePath(name)
+	if err != nil {
+		return mark.Header{}, nil, err
+	}
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return mark.Header{}, nil, fmt.Errorf("could not find template %s in %s", name, file)
+	}
+	if err != nil {
+		return mark.Header{}, nil, err
+	}
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("---")) {
+		return mark.Header{}, data, nil
+	}
+	return mark.UnmarshalNote(data)
+}
+
+// Expand executes the placeholders of the template title and content, i.e.
+//
+//	{{date}}, {{date "Jan 02"}}, {{time}}, {{weekday}} and {{prompt "Customer"}}
+//
+// A prompt with the same label is only asked once
+func Expand(meta mark.Header, content []byte, now time.Time, prompt Prompter) (mark.Header, []byte, error) {
+	answers := map[string]string{}
+	funcs := template.FuncMap{
+		"date": func(layout ...string) string {
+			if len(layout) > 0 {
+				return now.Format(layout[0])
+			}
+			return now.Format("2006-01-02")
+		},
+		"time": func() string {
+			return now.Format("15:04")
+		},
+		"weekday": func() string {
+			return now.Weekday().String()
+		},
+		"prompt": func(label string) (string, error) {
+			if answer, ok := answers[label]; ok {
+				return answer, nil
+			}
+			answer, err := prompt(label)
+			if err != nil {
+				return "", err
+			}
+			answers[label] = answer
+			return answer, nil
+		},
+	}
+
+	expand := func(name string, text string) (string, error) {
+		t, err := template.New(name).Funcs(funcs).Parse(text)
+		if err != nil {
+			return "", err
+		}
+		buf := bytes.NewBuffer(nil)
+		err = t.Execute(buf, nil)
+		return buf.String(), err
+	}
+
+	var err error
+	meta.Title, err = expand("title", meta.Title)
+	if err != nil {
+		return meta, nil, err
+	}
+	body, err := expand("content", string(content))
+	if err != nil {
+		return meta, nil, err
+	}
+	return meta, []byte(body), nil
+}
@@ -0,0 +1,51 @@
+package tmpl
+
+import (
+	"errors"
+	"github.com/crholm/mark"
+	"testing"
+	"time"
+)
+
+func TestExpand(t *testing.T) {
+	now := time.Date(2022, 8, 12, 14, 4, 49, 0, time.UTC)
+	var asked []string
+	prompt := func(label string) (string, error) {
+		asked = append(asked, label)
+		return "Acme", nil
+	}
+
+	for _, c := range []struct {
+		title, content          string
+		expectedTitle, expected string
+		asked                   int
+	}{
+		{"Standup {{date}}", "{{weekday}} at {{time}}", "Standup 2022-08-12", "Friday at 14:04", 0},
+		{"{{date \"Jan 02\"}}", "no placeholders", "Aug 12", "no placeholders", 0},
+		{"Meeting with {{prompt \"Customer\"}}", "# {{prompt \"Customer\"}}\n\n{{prompt \"Topic\"}}", "Meeting with Acme", "# Acme\n\nAcme", 2},
+	} {
+		asked = nil
+		meta, content, err := Expand(mark.Header{Title: c.title}, []byte(c.content), now, prompt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if meta.Title != c.expectedTitle || string(content) != c.expected {
+			t.Fatalf("expected %q and %q, got %q and %q", c.expectedTitle, c.expected, meta.Title, content)
+		}
+		// a prompt with the same label is only asked once
+		if len(asked) != c.asked {
+			t.Fatalf("expected %d prompts, got %v", c.asked, asked)
+		}
+	}
+
+	_, _, err := Expand(mark.Header{}, []byte("{{prompt \"Customer\"}}"), now, func(label string) (string, error) {
+		return "", errors.New("no stdin")
+	})
+	if err == nil {
+		t.Fatal("expected the error of the prompt")
+	}
+	_, _, err = Expand(mark.Header{}, []byte("{{unknown}}"), now, prompt)
+	if err == nil {
+		t.Fatal("expected an error for an unknown placeholder")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/modfin/henry/slicez"
 	"regexp"
 	"strings"
+	"unicode/utf8"
 )
 
 func GetTagsFromNote(content []byte) []string {
@@ -16,8 +17,66 @@ func GetTagsFromNote(content []byte) []string {
 }
 
 func TokenizeText(text string) []string {
+	return Tokenizer{}.Tokenize(text)
+}
+
+// Tokenizer splits text into the lower cased words of the free text index. Words shorter than MinLength, in
+// characters, and StopWords are left out
+type Tokenizer struct {
+	MinLength int
+	StopWords []string
+}
+
+func (t Tokenizer) Tokenize(text string) []string {
 	var splitter = regexp.MustCompile("(\\s+)|([!-/:-@[-`{-~])")
+	stop := map[string]bool{}
+	for _, w := range t.StopWords {
+		stop[strings.ToLower(strings.TrimSpace(w))] = true
+	}
 	return slicez.Filter(slicez.Map(splitter.Split(text, -1), func(word string) string {
 		return strings.ToLower(strings.TrimSpace(word))
+	}), func(s string) bool {
+		return len(s) > 0 && utf8.RuneCountInString(s) >= t.MinLength && !stop[s]
+	})
+}
+
+// GetLinksFromNote returns the targets of all [[wiki links]] in a note, a target
+// is either the alias of a note or its filename, e.g. [[todo]] or [[todo|my todo list]]
+func GetLinksFromNote(content []byte) []string {
+	r := regexp.MustCompile(`\[\[([^\[\]|]+)(\|[^\[\]]*)?]]`)
+	links := r.FindAllSubmatch(content, -1)
+	return slicez.Filter(slicez.Map(links, func(a [][]byte) string {
+		return string(bytes.TrimSpace(a[1]))
 	}), func(s string) bool { return len(s) > 0 })
 }
+
+// ReplaceLinks replaces the target of [[wiki links]] for which replace returns a new target, keeping any label
+func ReplaceLinks(content []byte, replace func(target string) (string, bool)) []byte {
+	r := regexp.MustCompile(`\[\[([^\[\]|]+)(\|[^\[\]]*)?]]`)
+	return r.ReplaceAllFunc(content, func(m []byte) []byte {
+		parts := r.FindSubmatch(m)
+		target, ok := replace(string(bytes.TrimSpace(parts[1])))
+		if !ok {
+			return m
+		}
+		return []byte("[[" + target + string(parts[2]) + "]]")
+	})
+}
+
+// EnsureTags appends the tags that is not already present in content as a line of #tags, since tags of a note are
+// always derived from its content
+func EnsureTags(content []byte, tags []string) []byte {
+	present := slicez.Uniq(GetTagsFromNote(content))
+	missing := slicez.Filter(slicez.Uniq(tags), func(tag string) bool {
+		return len(tag) > 0 && !slicez.Contains(present, tag)
+	})
+	if len(missing) == 0 {
+		return content
+	}
+	line := strings.Join(slicez.Map(missing, func(tag string) string { return "#" + tag }), " ")
+	content = append([]byte(nil), bytes.TrimRight(content, "\n")...)
+	if len(content) > 0 {
+		content = append(content, []byte("\n\n")...)
+	}
+	return append(content, []byte(line)...)
+}
@@ -0,0 +1,42 @@
+package ts
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetLinksFromNote(t *testing.T) {
+	for content, expected := range map[string][]string{
+		"no links":     nil,
+		"see [[todo]]": {"todo"},
+		"see [[todo|my todo list]] and [[ meeting ]]": {"todo", "meeting"},
+		"[[2022-08-12_14:04:49.000Z_Friday.md]]":      {"2022-08-12_14:04:49.000Z_Friday.md"},
+		"not [[]] or [[ ]] or [link](todo.md)":        nil,
+	} {
+		links := GetLinksFromNote([]byte(content))
+		if len(links) == 0 && len(expected) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(links, expected) {
+			t.Fatalf("expected the links of %q to be %v, got %v", content, expected, links)
+		}
+	}
+}
+
+func TestEnsureTags(t *testing.T) {
+	for _, c := range []struct {
+		content  string
+		tags     []string
+		expected string
+	}{
+		{"Buy milk #home", []string{"home"}, "Buy milk #home"},
+		{"Buy milk #home\n", []string{"home", "weekend", "weekend", ""}, "Buy milk #home\n\n#weekend"},
+		{"", []string{"meeting", "work"}, "#meeting #work"},
+		{"No tags", nil, "No tags"},
+	} {
+		content := EnsureTags([]byte(c.content), c.tags)
+		if string(content) != c.expected {
+			t.Fatalf("expected %q, got %q", c.expected, content)
+		}
+	}
+}
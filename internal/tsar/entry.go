@@ -150,6 +150,10 @@ func (l EntryList) ToIndex() *Index {
 		offset += e.length()
 	}
 
+	if len(entries) == 0 {
+		return &Index{}
+	}
+
 	checkpoints := func(lo, hi int) (res []uint32) {
 		for i := lo; i < hi; i += PartitionSize {
 			res = append(res, offsets[entries[i]])
@@ -54,6 +54,10 @@ func (i *Index) Find(needle string, match Matcher) ([]*Entry, error) {
 		return res, nil
 	}
 
+	if len(i.checkpoints) == 0 {
+		return nil, nil
+	}
+
 	entryAtOffset := func(offset uint32) (e *Entry, err error) {
 		seekOffset := i.offset + int64(offset)
 		_, err = i.reader.Seek(seekOffset, 0)
@@ -96,7 +100,11 @@ func (i *Index) Find(needle string, match Matcher) ([]*Entry, error) {
 }
 
 func MarshalIndex(i *Index) []byte {
-	var buf = make([]byte, 0, i.checkpoints[len(i.checkpoints)-1])
+	var size uint32
+	if len(i.checkpoints) > 0 {
+		size = i.checkpoints[len(i.checkpoints)-1]
+	}
+	var buf = make([]byte, 0, size)
 
 	buf = append(buf, bytesOfUint32(uint32(len(i.checkpoints)))...)
 
@@ -120,9 +128,11 @@ func UnmarshalIndexLazyReader(reader io.ReadSeeker) (*Index, error) {
 	numCheckpoints := int(uint32OfBytes(numCheckpointsBytes))
 
 	checkpointsBytes := make([]byte, numCheckpoints*CheckpointSize)
-	_, err = reader.Read(checkpointsBytes)
-	if err != nil {
-		return nil, err
+	if numCheckpoints > 0 { // an index without entries
+		_, err = reader.Read(checkpointsBytes)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	var checkpoints []uint32
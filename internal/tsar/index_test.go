@@ -121,6 +121,22 @@ func testIndexFind(source, test *Index, t *testing.T) {
 	}
 }
 
+func TestIndexEmpty(t *testing.T) {
+	data := MarshalIndex(NewEntryList().ToIndex())
+
+	i, err := UnmarshalIndex(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := i.Find("any", MatchPrefix)
+	if err != nil || len(res) != 0 {
+		t.Fatalf("expected nothing to be found in an empty index, got %v, %v", res, err)
+	}
+	if len(i.EntryList()) != 0 {
+		t.Fatalf("expected an empty entry list, got %v", i.EntryList())
+	}
+}
+
 func TestIndexFind(t *testing.T) {
 
 	var list = NewEntryList()
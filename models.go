@@ -9,11 +9,28 @@ import (
 )
 
 type Header struct {
-	Title     string    `yaml:"title"`
-	Alias     string    `yaml:"alias"`
-	Tags      []string  `yaml:"tags"`
-	CreatedAt time.Time `yaml:"created_at"`
-	UpdatedAt time.Time `yaml:"updated_at"`
+	Title     string    `yaml:"title" json:"title"`
+	Alias     string    `yaml:"alias" json:"alias"`
+	Tags      []string  `yaml:"tags" json:"tags"`
+	CreatedAt time.Time `yaml:"created_at" json:"created_at"`
+	UpdatedAt time.Time `yaml:"updated_at" json:"updated_at"`
+	Kind      string    `yaml:"kind,omitempty" json:"kind,omitempty"`
+	// Encrypted is set when the body of the note is encrypted, the header is always kept in the clear
+	Encrypted bool `yaml:"encrypted,omitempty" json:"encrypted,omitempty"`
+	// Attachments are files attached to the note, stored by content in the assets dir of the storage
+	Attachments []Attachment `yaml:"attachments,omitempty" json:"attachments,omitempty"`
+
+	// Extra holds any front matter fields unknown to mark, e.g. from notes created by other tools
+	Extra map[string]interface{} `yaml:",inline" json:"extra,omitempty"`
+}
+
+const KindJournal = "journal"
+
+type Attachment struct {
+	// Name is the name of the file as it was attached, e.g. photo.png
+	Name string `yaml:"name" json:"name"`
+	// Asset is the name of the file in the assets dir, i.e. the sha256 of the content along with the extension
+	Asset string `yaml:"asset" json:"asset"`
 }
 
 type Index struct {